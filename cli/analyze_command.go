@@ -2,6 +2,7 @@ package cli
 
 import (
     "fmt"
+    "math"
     "strings"
 
     "srg.de/jb/air_task3/survey"
@@ -15,68 +16,100 @@ func (c *AnalyzeCommand) Aliases() []string { return []string{"distribution", "d
 
 func (c *AnalyzeCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
     if len(args) < 1 {
-        return true, fmt.Errorf("missing question key")
+        return true, fmt.Errorf("usage: analyze <question_key>|@<block> [<ResponseQuery>]")
     }
     questionKey := args[0]
-    entry, ok := data.Schema.Get(questionKey)
-    if !ok {
-        return true, fmt.Errorf("question %q not found", questionKey)
-    }
-    if entry.QType != survey.SC && entry.QType != survey.MC {
-        return true, fmt.Errorf("question %q is not single or multi choice", questionKey)
+
+    // A query restricts the analysis to the responses it selects
+    var rows []int
+    if len(args) > 1 {
+        query, err := parseQuery(data, args[1])
+        if err != nil {
+            return true, err
+        }
+        if rows, err = query.Select(data, data.Rows()); err != nil {
+            return true, err
+        }
     }
 
-    // Prepare option counts
-    counts := map[string]int{}
-    for _, opt := range entry.UsedOptions {
-        counts[opt] = 0
+    if name, ok := strings.CutPrefix(questionKey, survey.BlockPrefix); ok {
+        return true, analyzeBlock(data, name, rows)
+    }
+    if entry, ok := data.Schema.Get(questionKey); ok && entry.QType == survey.MATRIX {
+        m, err := data.Matrix(questionKey, rows)
+        if err != nil {
+            return true, err
+        }
+        outputMatrix(data, m)
+        return true, nil
     }
-    counts["(n/a)"] = 0
+    dist, err := data.Distribution(questionKey, rows)
+    if err != nil {
+        return true, err
+    }
+    outputDistribution(data, dist)
+    return true, nil
+}
 
-    for _, resp := range data.Responses {
-        val, ok := resp[entry.Key]
-        if !ok || !val.Present() {
-            counts["(n/a)"]++
+// analyzeBlock prints the distributions of all single and multiple choice
+// questions of a block over the given rows, or over all responses if rows
+// is nil. Matrix rows are shown in their matrix's table.
+func analyzeBlock(data *survey.SurveyData, name string, rows []int) error {
+    entries, ok := data.Schema.Block(name)
+    if !ok {
+        return fmt.Errorf("unknown block %q, blocks are: %s", name, strings.Join(data.Schema.Blocks(), ", "))
+    }
+    outputBlockHeading(entries[0].Block)
+    for _, entry := range entries {
+        if entry.QType == survey.MATRIX {
+            m, err := data.Matrix(entry.Key, rows)
+            if err != nil {
+                return err
+            }
+            outputMatrix(data, m)
+            fmt.Println()
             continue
         }
-        if entry.QType == survey.SC {
-            strVal, ok := val.AsString()
-            if !ok || strVal == "" {
-                counts["(n/a)"]++
-                continue
-            }
-            if _, exists := counts[strVal]; exists {
-                counts[strVal]++
-            } else {
-                counts[strVal] = 1
-            }
-        } else if entry.QType == survey.MC {
-            strVals, ok := val.AsStringSlice()
-            if !ok || len(strVals) == 0 {
-                counts["(n/a)"]++
-                continue
-            }
-            seen := map[string]bool{}
-            for _, opt := range strVals {
-                if _, exists := counts[opt]; exists {
-                    counts[opt]++
-                } else {
-                    counts[opt] = 1
-                }
-                seen[opt] = true
-            }
-            // If no valid options found, count as n/a
-            if len(seen) == 0 {
-                counts["(n/a)"]++
-            }
+        if entry.QType != survey.SC && entry.QType != survey.MC || entry.Matrix != "" {
+            continue
+        }
+        dist, err := data.Distribution(entry.Key, rows)
+        if err != nil {
+            return err
+        }
+        outputDistribution(data, dist)
+        fmt.Println()
+    }
+    return nil
+}
+
+func outputDistribution(data *survey.SurveyData, dist *survey.Distribution) {
+    entry := dist.Entry
+
+    // Option counts in option order, n/a last
+    type optionCount struct {
+        opt      string
+        cnt      int
+        weighted float64
+    }
+    // Options in declared order, including those nobody chose; answers
+    // that aren't declared options are marked
+    counts := make([]optionCount, 0, len(entry.UsedOptions)+1)
+    undeclared := false
+    for code, opt := range entry.UsedOptions {
+        if !entry.Declared(opt) {
+            opt += " *"
+            undeclared = true
         }
+        counts = append(counts, optionCount{opt, dist.Counts[code], dist.Weighted[code]})
     }
+    counts = append(counts, optionCount{"(n/a)", dist.NA, dist.WeightedNA})
 
     // Output
     // Find max width for option column (capped at 25)
     maxOptLen := 0
-    for opt := range counts {
-        l := len(opt)
+    for _, c := range counts {
+        l := len(c.opt)
         if l > 25 {
             l = 25
         }
@@ -89,18 +122,32 @@ func (c *AnalyzeCommand) Run(cmd string, args []string, data *survey.SurveyData)
     }
     optFmt := fmt.Sprintf("  %%-%ds", maxOptLen)
     numFmt := "%6d"
-    pctFmt := "%7.1f%%%%"
+    weightFmt := "%10.1f"
+    pctFmt := "%7.1f%%"
     graphLen := 15
 
-    fmt.Printf("Distribution for [%s] (%s):\n", entry.Key, entry.QType)
-    total := 0
-    for _, cnt := range counts {
-        total += cnt
+    // Percentages are weighted if weights are set; the unweighted n is
+    // shown next to the weighted count
+    weighted := data.Weighted()
+    if weighted {
+        fmt.Printf("Distribution for [%s] (%s), weighted by %s:\n", entry.Key, entry.QType, data.WeightSource)
+        fmt.Printf(optFmt, "")
+        fmt.Printf(" %6s %10s\n", "n", "weighted")
+    } else {
+        fmt.Printf("Distribution for [%s] (%s):\n", entry.Key, entry.QType)
     }
-    for opt, cnt := range counts {
+    total := float64(dist.Total())
+    if weighted {
+        total = dist.WeightedTotal()
+    }
+    for _, c := range counts {
+        opt, cnt := c.opt, float64(c.cnt)
+        if weighted {
+            cnt = c.weighted
+        }
         percent := 0.0
         if total > 0 {
-            percent = float64(cnt) * 100.0 / float64(total)
+            percent = cnt * 100.0 / total
         }
         displayOpt := opt
         if len(displayOpt) > 25 {
@@ -109,7 +156,7 @@ func (c *AnalyzeCommand) Run(cmd string, args []string, data *survey.SurveyData)
         // ASCII bar graph
         barCount := 0
         if total > 0 {
-            barCount = int((float64(cnt)/float64(total))*float64(graphLen) + 0.5)
+            barCount = int((cnt/total)*float64(graphLen) + 0.5)
         }
         if barCount > graphLen {
             barCount = graphLen
@@ -117,10 +164,80 @@ func (c *AnalyzeCommand) Run(cmd string, args []string, data *survey.SurveyData)
         bar := strings.Repeat("█", barCount) + strings.Repeat(" ", graphLen-barCount)
 
         fmt.Printf(optFmt, displayOpt)
-        fmt.Printf(" "+numFmt+" "+pctFmt+" |%s|\n", cnt, percent, bar)
+        fmt.Printf(" "+numFmt, c.cnt)
+        if weighted {
+            fmt.Printf(" "+weightFmt, c.weighted)
+        }
+        fmt.Printf(" "+pctFmt+" |%s|\n", percent, bar)
     }
     // Align "Total" with the count column
     fmt.Printf(optFmt, "Total")
-    fmt.Printf(" "+numFmt+" "+pctFmt+"\n", total, 100.0)
-    return true, nil
+    fmt.Printf(" "+numFmt, dist.Total())
+    if weighted {
+        fmt.Printf(" "+weightFmt, total)
+    }
+    fmt.Printf(" "+pctFmt+"\n", 100.0)
+    if code, ok := dist.Median(); ok {
+        fmt.Printf("  Median: %s\n", entry.UsedOptions[code])
+    }
+    if undeclared {
+        fmt.Println("  * not a declared option")
+    }
+}
+
+// outputMatrix prints the rows of a matrix question as one stacked table
+// of scale point shares, with the mean and top-2-box score per row if the
+// scale is declared.
+func outputMatrix(data *survey.SurveyData, m *survey.MatrixAnalysis) {
+    entry := m.Entry
+    if data.Weighted() {
+        fmt.Printf("Matrix [%s] (%d rows), weighted by %s:\n", entry.Key, len(m.Rows), data.WeightSource)
+    } else {
+        fmt.Printf("Matrix [%s] (%d rows):\n", entry.Key, len(m.Rows))
+    }
+    fmt.Printf("    %s\n", entry.Text)
+    points := make([]string, len(m.Scale))
+    for i, point := range m.Scale {
+        points[i] = fmt.Sprintf("%d = %s", i+1, point)
+    }
+    fmt.Printf("  Scale: %s\n", strings.Join(points, ", "))
+
+    keyLen := 3
+    for _, row := range m.Rows {
+        keyLen = max(keyLen, min(len(row.Entry.Key), 25))
+    }
+    keyFmt := fmt.Sprintf("  %%-%ds", keyLen)
+    fmt.Printf(keyFmt+" %6s", "Row", "n")
+    for i := range m.Scale {
+        fmt.Printf(" %7d", i+1)
+    }
+    if m.Ordinal {
+        fmt.Printf(" %6s %7s", "Mean", "Top-2")
+    }
+    fmt.Println()
+    var other []string
+    for _, row := range m.Rows {
+        key := row.Entry.Key
+        if len(key) > 25 {
+            key = key[:22] + "..."
+        }
+        fmt.Printf(keyFmt+" %6d", key, row.Answered)
+        for _, share := range row.Shares {
+            fmt.Printf(" %6.1f%%", share*100)
+        }
+        if m.Ordinal {
+            if math.IsNaN(row.Mean) {
+                fmt.Printf(" %6s %7s", "-", "-")
+            } else {
+                fmt.Printf(" %6.2f %6.1f%%", row.Mean, row.TopBox*100)
+            }
+        }
+        fmt.Println()
+        if row.Other > 0 {
+            other = append(other, fmt.Sprintf("%s (%d)", row.Entry.Key, row.Other))
+        }
+    }
+    if len(other) > 0 {
+        fmt.Printf("  Answers not on the scale, left out: %s\n", strings.Join(other, ", "))
+    }
 }
@@ -23,6 +23,14 @@ var (
         &ResponsesCommand{},
         &SubsetCommand{},
         &AnalyzeCommand{},
+        &TrendCommand{},
+        &WeightCommand{},
+        &RakeCommand{},
+        &TextCommand{},
+        &NormalizeCommand{},
+        &DeriveCommand{},
+        &ValidateCommand{},
+        &ShowCommand{},
     }
 )
 
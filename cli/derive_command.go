@@ -0,0 +1,77 @@
+package cli
+
+import (
+    "fmt"
+    "strings"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// DeriveCommand defines derived questions and stores their definitions in
+// the project file.
+type DeriveCommand struct{}
+
+func (c *DeriveCommand) Name() string { return "derive" }
+
+func (c *DeriveCommand) Aliases() []string { return []string{"der"} }
+
+func (c *DeriveCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    if len(args) == 0 {
+        derived := 0
+        for _, entry := range data.Schema {
+            if entry.Derived != "" {
+                fmt.Printf("[%s] (%s) = %s\n", entry.Key, entry.QType, entry.Derived)
+                derived++
+            }
+        }
+        if derived == 0 {
+            fmt.Println("No derived questions.")
+        }
+        return true, nil
+    }
+    if data.Project == nil {
+        return true, fmt.Errorf("no project file to store derived questions in")
+    }
+
+    if args[0] == "remove" {
+        if len(args) < 2 {
+            return true, fmt.Errorf("missing question key")
+        }
+        if err := data.RemoveDerived(args[1]); err != nil {
+            return true, err
+        }
+        data.Project.RemoveDerived(args[1])
+        if err := data.Project.Save(); err != nil {
+            return true, fmt.Errorf("failed to save %s: %w", data.Project.Filename, err)
+        }
+        fmt.Printf("Removed [%s].\n", args[1])
+        return true, nil
+    }
+
+    if len(args) < 2 {
+        return true, fmt.Errorf("usage: derive <key>[:<type>] <expression> | derive remove <key>")
+    }
+    key, qtype, _ := strings.Cut(args[0], ":")
+    dv := survey.DerivedVar{Key: key, Type: survey.QuestionType(strings.ToUpper(qtype))}
+    switch dv.Type {
+    case "", survey.SC, survey.MC, survey.TE, survey.NUM:
+    default:
+        return true, fmt.Errorf("unknown question type %q", qtype)
+    }
+    exprArgs := args[1:]
+    if exprArgs[0] == "=" {
+        exprArgs = exprArgs[1:]
+    }
+    dv.Expr = strings.Join(exprArgs, " ")
+
+    entry, err := data.Derive(dv)
+    if err != nil {
+        return true, err
+    }
+    data.Project.SetDerived(dv)
+    if err := data.Project.Save(); err != nil {
+        return true, fmt.Errorf("failed to save %s: %w", data.Project.Filename, err)
+    }
+    outputSchemaEntry(entry, 0, 0)
+    return true, nil
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
     "fmt"
+    "strings"
 
     "srg.de/jb/air_task3/survey"
 )
@@ -14,7 +15,18 @@ func (c *ListCommand) Name() string { return "list" }
 func (c *ListCommand) Aliases() []string { return []string{"ls"} }
 
 func (c *ListCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
-    // Output in the order as in the file (insertion order of Schema)
+    if len(args) > 0 {
+        name := strings.TrimPrefix(args[0], survey.BlockPrefix)
+        entries, ok := data.Schema.Block(name)
+        if !ok {
+            return true, fmt.Errorf("unknown block %q, blocks are: %s", name, strings.Join(data.Schema.Blocks(), ", "))
+        }
+        fmt.Println("Survey Questions:")
+        outputSchemaEntries(entries)
+        return true, nil
+    }
+    // Output in the order as in the file (insertion order of Schema),
+    // grouped by block
     fmt.Println("Survey Questions:")
     outputSchemaEntries(data.Schema)
     return true, nil
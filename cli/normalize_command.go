@@ -0,0 +1,119 @@
+package cli
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// NormalizeCommand suggests near-duplicate options and records accepted
+// merges in the normalization rules file.
+type NormalizeCommand struct {
+    suggestions []survey.MergeSuggestion
+}
+
+func (c *NormalizeCommand) Name() string { return "normalize" }
+
+func (c *NormalizeCommand) Aliases() []string { return []string{"norm"} }
+
+func (c *NormalizeCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    if len(args) < 1 {
+        if data.RulesFile == "" {
+            fmt.Println("No normalization rules file.")
+        } else {
+            fmt.Printf("Normalization rules file: %s\n", data.RulesFile)
+        }
+        return true, nil
+    }
+    switch args[0] {
+    case "suggest":
+        return true, c.suggest(args[1:], data)
+    case "accept":
+        return true, c.accept(args[1:], data)
+    default:
+        return true, fmt.Errorf("usage: normalize [suggest <question_key>|* [distance=<n>] | accept <n>...|all]")
+    }
+}
+
+func (c *NormalizeCommand) suggest(args []string, data *survey.SurveyData) error {
+    if len(args) < 1 {
+        return fmt.Errorf("missing question key")
+    }
+    maxDistance := 2
+    for _, arg := range args[1:] {
+        value, ok := strings.CutPrefix(arg, "distance=")
+        if !ok {
+            return fmt.Errorf("unknown option %q", arg)
+        }
+        d, err := strconv.Atoi(value)
+        if err != nil || d < 0 {
+            return fmt.Errorf("invalid distance %q", value)
+        }
+        maxDistance = d
+    }
+    keys := []string{args[0]}
+    if args[0] == "*" {
+        keys = nil
+        for _, entry := range data.Schema {
+            if entry.QType == survey.SC || entry.QType == survey.MC {
+                keys = append(keys, entry.Key)
+            }
+        }
+    }
+
+    c.suggestions = nil
+    for _, key := range keys {
+        suggestions, err := data.SuggestMerges(key, maxDistance)
+        if err != nil {
+            return err
+        }
+        c.suggestions = append(c.suggestions, suggestions...)
+    }
+    if len(c.suggestions) == 0 {
+        fmt.Println("No similar options found.")
+        return nil
+    }
+    width := len(strconv.Itoa(len(c.suggestions)))
+    for i, s := range c.suggestions {
+        fmt.Printf("%*d. [%s] %q (%d) -> %q (%d), distance %d\n",
+            width, i+1, s.Key, s.From, s.FromCount, s.To, s.ToCount, s.Distance)
+    }
+    fmt.Println("Use 'normalize accept <n>...' or 'normalize accept all' to merge.")
+    return nil
+}
+
+func (c *NormalizeCommand) accept(args []string, data *survey.SurveyData) error {
+    if len(c.suggestions) == 0 {
+        return fmt.Errorf("no suggestions, run 'normalize suggest' first")
+    }
+    if data.RulesFile == "" {
+        return fmt.Errorf("no normalization rules file to record merges in")
+    }
+    var accepted []survey.MergeSuggestion
+    if len(args) == 1 && args[0] == "all" {
+        accepted = c.suggestions
+    } else {
+        for _, arg := range args {
+            i, err := strconv.Atoi(arg)
+            if err != nil || i < 1 || i > len(c.suggestions) {
+                return fmt.Errorf("invalid suggestion %q", arg)
+            }
+            accepted = append(accepted, c.suggestions[i-1])
+        }
+    }
+    for _, s := range accepted {
+        if err := data.MergeOption(s.Key, s.From, s.To); err != nil {
+            return err
+        }
+        if err := survey.AppendAliasRule(data.RulesFile, s.Key, s.From, s.To); err != nil {
+            return fmt.Errorf("failed to write %s: %w", data.RulesFile, err)
+        }
+        fmt.Printf("Merged [%s] %q into %q\n", s.Key, s.From, s.To)
+    }
+    // Suggestion numbers refer to the options before merging
+    c.suggestions = nil
+    fmt.Printf("Recorded %d rule(s) in %s; the cache is rebuilt on the next start.\n", len(accepted), data.RulesFile)
+    return nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// RakeCommand computes respondent weights by raking to target shares and
+// uses them for all following commands.
+type RakeCommand struct{}
+
+func (c *RakeCommand) Name() string { return "rake" }
+
+func (c *RakeCommand) Aliases() []string { return []string{"raking"} }
+
+func (c *RakeCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    if len(args) < 1 {
+        return true, fmt.Errorf("usage: rake <targets file> [save=<key>] [iterations=<n>] [tolerance=<x>]")
+    }
+    targets, err := survey.LoadRakeTargets(args[0])
+    if err != nil {
+        return true, err
+    }
+    var opts survey.RakeOptions
+    saveKey := ""
+    for _, arg := range args[1:] {
+        name, value, ok := strings.Cut(arg, "=")
+        if !ok {
+            return true, fmt.Errorf("invalid option %q, expected <name>=<value>", arg)
+        }
+        switch name {
+        case "save":
+            saveKey = value
+        case "iterations":
+            if opts.MaxIterations, err = strconv.Atoi(value); err != nil {
+                return true, fmt.Errorf("invalid iterations %q", value)
+            }
+        case "tolerance":
+            if opts.Tolerance, err = strconv.ParseFloat(value, 64); err != nil {
+                return true, fmt.Errorf("invalid tolerance %q", value)
+            }
+        default:
+            return true, fmt.Errorf("unknown option %q", name)
+        }
+    }
+
+    res, err := data.Rake(targets, opts)
+    if err != nil {
+        return true, err
+    }
+    keys := make([]string, len(targets))
+    for i, t := range targets {
+        keys[i] = t.Key
+    }
+    fmt.Printf("Raking on %s:\n", strings.Join(keys, ", "))
+    if res.Converged {
+        fmt.Printf("  converged after %d iterations (max error %.2g)\n", res.Iterations, res.MaxError)
+    } else {
+        fmt.Printf("  NOT converged after %d iterations (max error %.2g)\n", res.Iterations, res.MaxError)
+    }
+    fmt.Printf("  design effect: %.3f, effective n: %.1f of %d\n",
+        res.DesignEffect, float64(data.Len())/res.DesignEffect, data.Len())
+    fmt.Printf("  weights: min %.3f, max %.3f\n", res.Min, res.Max)
+
+    if saveKey != "" {
+        if err := data.SetNumericColumn(saveKey, "Raking weight ("+args[0]+")", res.Weights); err != nil {
+            return true, err
+        }
+        if err := data.UseWeightColumn(saveKey); err != nil {
+            return true, err
+        }
+        fmt.Printf("Saved weights as [%s].\n", saveKey)
+    } else if err := data.SetWeights(res.Weights, "raking to "+args[0]); err != nil {
+        return true, err
+    }
+    fmt.Printf("Responses are weighted by %s.\n", data.WeightSource)
+    return true, nil
+}
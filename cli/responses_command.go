@@ -11,18 +11,24 @@ func (c *ResponsesCommand) Name() string { return "responses" }
 func (c *ResponsesCommand) Aliases() []string { return []string{"response", "resp"} }
 
 func (c *ResponsesCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
-    responses := data.Responses
+    rows := data.Rows()
     var showKeys []string
     if len(args) > 0 {
         queryString := args[0]
-        query, err := survey.ParseResponseQuery(queryString)
+        query, err := parseQuery(data, queryString)
         if err != nil {
             return true, err
         }
-        responses = query.Limit(responses)
-        showKeys = query.Keys
+        rows, err = query.Select(data, rows)
+        if err != nil {
+            return true, err
+        }
+        if showKeys, err = query.ResolveKeys(data.Schema); err != nil {
+            return true, err
+        }
+        outputResolvedKeys(query.Keys, showKeys)
     }
 
-    outputResponses(data.Schema, responses, showKeys)
+    outputResponses(data, rows, showKeys)
     return true, nil
 }
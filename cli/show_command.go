@@ -0,0 +1,51 @@
+package cli
+
+import (
+    "fmt"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// ShowCommand prints all answers of one or more respondents.
+type ShowCommand struct{}
+
+func (c *ShowCommand) Name() string { return "show" }
+
+func (c *ShowCommand) Aliases() []string { return []string{"respondent"} }
+
+func (c *ShowCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    if len(args) == 0 {
+        return true, fmt.Errorf("usage: show <id>...")
+    }
+    rows := make([]int, len(args))
+    for i, id := range args {
+        row, ok := data.RowByID(id)
+        if !ok {
+            if data.IDKey == "" {
+                return true, fmt.Errorf("no response %q, responses are numbered from 1 to %d", id, data.Len())
+            }
+            return true, fmt.Errorf("no response with %s %q", data.IDKey, id)
+        }
+        rows[i] = row
+    }
+    groups := groupByBlock(data.Schema)
+    for _, row := range rows {
+        fmt.Printf("%s:\n", responseLabel(data, row))
+        if data.Weighted() {
+            fmt.Printf("    (weight %.3f)\n", data.Weight(row))
+        }
+        resp := data.Response(row)
+        for _, g := range groups {
+            if len(groups) > 1 || g.name != "" {
+                fmt.Print("  ")
+                outputBlockHeading(g.name)
+            }
+            for _, entry := range g.entries {
+                if entry.QType != survey.MATRIX {
+                    outputResponseValue(entry, resp)
+                }
+            }
+        }
+    }
+    return true, nil
+}
@@ -26,10 +26,15 @@ func (c *SubsetCommand) Run(cmd string, args []string, data *survey.SurveyData)
         return true, fmt.Errorf("question %q not found", questionKey)
     }
 
-    found := []survey.Response{}
-    for _, resp := range data.Responses {
-        val, ok := resp[entry.Key]
-        if !ok || !val.Present() {
+    col, ok := data.Column(entry.Key)
+    if !ok {
+        return true, fmt.Errorf("question %q has no data", questionKey)
+    }
+
+    found := []int{}
+    for row := 0; row < data.Len(); row++ {
+        val := col.Value(row)
+        if !val.Present() {
             continue
         }
         if entry.QType == survey.SC {
@@ -38,7 +43,7 @@ func (c *SubsetCommand) Run(cmd string, args []string, data *survey.SurveyData)
                 continue
             }
             if strings.ToLower(strVal) == option {
-                found = append(found, resp)
+                found = append(found, row)
             }
         } else if entry.QType == survey.MC {
             strVal, ok := val.AsStringSlice()
@@ -47,28 +52,45 @@ func (c *SubsetCommand) Run(cmd string, args []string, data *survey.SurveyData)
             }
             for _, opt := range strVal {
                 if strings.ToLower(opt) == option {
-                    found = append(found, resp)
+                    found = append(found, row)
                     break
                 }
             }
         }
     }
 
+    matched := len(found)
+    weightedMatched := data.WeightedCount(found)
+
     showKeys := []string{questionKey}
     if len(args) > 2 {
         queryString := args[2]
-        query, err := survey.ParseResponseQuery(queryString)
+        query, err := parseQuery(data, queryString)
+        if err != nil {
+            return true, err
+        }
+        found, err = query.Select(data, found)
+        if err != nil {
+            return true, err
+        }
+        keys, err := query.ResolveKeys(data.Schema)
         if err != nil {
             return true, err
         }
-        found = query.Limit(found)
-        if slices.Contains(query.Keys, "*") {
-            showKeys = nil
-        } else {
-            showKeys = append(showKeys, query.Keys...)
+        outputResolvedKeys(query.Keys, keys)
+        for _, key := range keys {
+            if !slices.Contains(showKeys, key) {
+                showKeys = append(showKeys, key)
+            }
         }
     }
 
-    outputResponses(data.Schema, found, showKeys)
+    outputResponses(data, found, showKeys)
+    if data.Weighted() {
+        fmt.Printf("%d of %d responses match (weighted %.1f of %.1f)\n",
+            matched, data.Len(), weightedMatched, data.WeightedCount(data.Rows()))
+    } else {
+        fmt.Printf("%d of %d responses match\n", matched, data.Len())
+    }
     return true, nil
 }
@@ -0,0 +1,105 @@
+package cli
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// TextCommand reports the most frequent terms, n-grams and answers of a
+// free-text question.
+type TextCommand struct{}
+
+func (c *TextCommand) Name() string { return "text" }
+
+func (c *TextCommand) Aliases() []string { return []string{"terms", "te"} }
+
+func (c *TextCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    if len(args) < 1 {
+        return true, fmt.Errorf("usage: text <question_key> [<ResponseQuery>] [top=<n>] [stopwords=<file>|none] [fold=all|case|none]")
+    }
+    questionKey := args[0]
+
+    opts := survey.TextOptions{
+        Stopwords:   survey.DefaultStopwords,
+        FoldCase:    true,
+        FoldAccents: true,
+        Top:         10,
+    }
+    rows := data.Rows()
+    for _, arg := range args[1:] {
+        name, value, ok := strings.Cut(arg, "=")
+        switch {
+        case ok && name == "top":
+            top, err := strconv.Atoi(value)
+            if err != nil || top < 0 {
+                return true, fmt.Errorf("invalid top %q", value)
+            }
+            opts.Top = top
+        case ok && name == "stopwords":
+            if value == "none" {
+                opts.Stopwords = nil
+                continue
+            }
+            sw, err := survey.LoadStopwords(value)
+            if err != nil {
+                return true, err
+            }
+            opts.Stopwords = sw
+        case ok && name == "fold":
+            switch value {
+            case "all":
+                opts.FoldCase, opts.FoldAccents = true, true
+            case "case":
+                opts.FoldCase, opts.FoldAccents = true, false
+            case "none":
+                opts.FoldCase, opts.FoldAccents = false, false
+            default:
+                return true, fmt.Errorf("invalid fold %q, use all, case or none", value)
+            }
+        default:
+            query, err := parseQuery(data, arg)
+            if err != nil {
+                return true, err
+            }
+            rows, err = query.Select(data, rows)
+            if err != nil {
+                return true, err
+            }
+        }
+    }
+
+    ta, err := data.AnalyzeText(questionKey, rows, opts)
+    if err != nil {
+        return true, err
+    }
+
+    fmt.Printf("Text analysis for [%s] (%s): %d answers, %d n/a\n", ta.Entry.Key, ta.Entry.QType, ta.Answered, ta.NA)
+    weighted := data.Weighted()
+    outputTermCounts("Top terms", ta.Terms, weighted)
+    outputTermCounts("Top bigrams", ta.Bigrams, weighted)
+    outputTermCounts("Top trigrams", ta.Trigrams, weighted)
+    outputTermCounts("Most frequent answers", ta.Answers, weighted)
+    return true, nil
+}
+
+func outputTermCounts(title string, counts []survey.TermCount, weighted bool) {
+    fmt.Printf("  %s:\n", title)
+    if len(counts) == 0 {
+        fmt.Println("    (none)")
+        return
+    }
+    for _, c := range counts {
+        term := c.Term
+        if len(term) > 50 {
+            term = term[:47] + "..."
+        }
+        if weighted {
+            fmt.Printf("    %6d %10.1f  %s\n", c.Count, c.Weighted, term)
+        } else {
+            fmt.Printf("    %6d  %s\n", c.Count, term)
+        }
+    }
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+    "fmt"
+    "strings"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// TrendCommand compares the option shares of a question across survey
+// years. Loaded datasets are kept so that repeated comparisons are fast.
+type TrendCommand struct {
+    loaded map[string]*survey.SurveyData
+}
+
+func (c *TrendCommand) Name() string { return "trend" }
+
+func (c *TrendCommand) Aliases() []string { return []string{"years"} }
+
+func (c *TrendCommand) load(filename string) (*survey.SurveyData, error) {
+    if data, ok := c.loaded[filename]; ok {
+        return data, nil
+    }
+    data, err := survey.ReadSurveyDataCached(filename)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load %s: %w", filename, err)
+    }
+    if c.loaded == nil {
+        c.loaded = make(map[string]*survey.SurveyData)
+    }
+    c.loaded[filename] = data
+    return data, nil
+}
+
+func (c *TrendCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    if len(args) < 2 {
+        return true, fmt.Errorf("usage: trend <question_key> <year>=<file>... [keymap=<file>] [<ResponseQuery>]")
+    }
+    questionKey := args[0]
+
+    my := &survey.MultiYear{}
+    queryArg := ""
+    for _, arg := range args[1:] {
+        if isQueryArg(arg) {
+            queryArg = arg
+            continue
+        }
+        label, filename, ok := strings.Cut(arg, "=")
+        if !ok || label == "" || filename == "" {
+            return true, fmt.Errorf("invalid dataset %q, expected <year>=<file>", arg)
+        }
+        if label == "keymap" {
+            km, err := survey.LoadKeyMap(filename)
+            if err != nil {
+                return true, err
+            }
+            my.Keys = km
+            continue
+        }
+        yearData, err := c.load(filename)
+        if err != nil {
+            return true, err
+        }
+        my.Years = append(my.Years, survey.YearData{Label: label, Data: yearData})
+    }
+    if len(my.Years) == 0 {
+        return true, fmt.Errorf("no datasets given")
+    }
+
+    // A query selects the responses to compare in every year. It is checked
+    // against each year's schema, as the years differ in their questions
+    // and options.
+    if queryArg != "" {
+        for i, year := range my.Years {
+            query, err := checkQuery(year.Data.Schema, queryArg)
+            if err != nil {
+                return true, fmt.Errorf("%s: %w", year.Label, err)
+            }
+            if i == 0 {
+                if s := query.String(); s != "" {
+                    fmt.Printf("Query: %s\n", s)
+                }
+            }
+            if my.Years[i].Rows, err = query.Select(year.Data, year.Data.Rows()); err != nil {
+                return true, fmt.Errorf("%s: %w", year.Label, err)
+            }
+        }
+    }
+
+    trend, err := my.Trend(questionKey)
+    if err != nil {
+        return true, err
+    }
+
+    // Output
+    // Find max width for option column (capped at 25)
+    maxOptLen := 5
+    for _, opt := range trend.Options {
+        maxOptLen = max(maxOptLen, min(len(opt), 25))
+    }
+    optFmt := fmt.Sprintf("  %%-%ds", maxOptLen)
+    colWidth := 8
+    for _, year := range trend.Years {
+        colWidth = max(colWidth, len(year))
+    }
+    cellFmt := fmt.Sprintf(" %%%ds", colWidth)
+
+    fmt.Printf("Trend for [%s] (%s):\n", questionKey, trend.QType)
+    fmt.Printf(optFmt, "")
+    for _, year := range trend.Years {
+        fmt.Printf(cellFmt, year)
+    }
+    fmt.Println()
+    for i, opt := range trend.Options {
+        displayOpt := opt
+        if len(displayOpt) > 25 {
+            displayOpt = displayOpt[:22] + "..."
+        }
+        fmt.Printf(optFmt, displayOpt)
+        for y := range trend.Years {
+            // Options a year didn't have are shown as "-"
+            cell := "-"
+            if trend.Offered[i][y] {
+                cell = fmt.Sprintf("%.1f%%", trend.Shares[i][y]*100)
+            }
+            fmt.Printf(cellFmt, cell)
+        }
+        fmt.Println()
+    }
+    fmt.Printf(optFmt, "n")
+    for y := range trend.Years {
+        cell := "-"
+        if trend.Asked[y] {
+            cell = fmt.Sprintf("%d", trend.Respondents[y])
+        }
+        fmt.Printf(cellFmt, cell)
+    }
+    fmt.Println()
+    return true, nil
+}
+
+// isQueryArg reports whether a trend argument is a ResponseQuery rather
+// than a dataset: queries start with a section name.
+func isQueryArg(arg string) bool {
+    arg = strings.TrimSpace(arg)
+    for _, section := range []string{"keys", "ids", "where", "sample", "sort", "range"} {
+        if strings.HasPrefix(arg, section+":") || strings.HasPrefix(arg, section+"=") {
+            return true
+        }
+    }
+    return false
+}
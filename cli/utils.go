@@ -3,6 +3,7 @@ package cli
 import (
     "fmt"
     "slices"
+    "strings"
 
     "srg.de/jb/air_task3/survey"
 )
@@ -17,20 +18,81 @@ func outputSchemaEntry(entry *survey.SchemaEntry, i int, w int) {
         fmt.Printf("[%s] (%s)\n", entry.Key, entry.QType)
     }
     fmt.Printf("    %s\n", entry.Text)
+    if entry.Derived != "" && entry.Derived != entry.Text {
+        fmt.Printf("    = %s\n", entry.Derived)
+    }
+    if entry.Matrix != "" {
+        fmt.Printf("    Row of matrix [%s]\n", entry.Matrix)
+    }
+    if entry.QType == survey.MATRIX {
+        if len(entry.Scale) > 0 {
+            fmt.Printf("    Scale: %s\n", strings.Join(entry.Scale, " < "))
+        }
+        fmt.Printf("    Rows: %s\n", strings.Join(entry.Items, ", "))
+    }
     if ((entry.QType == survey.SC) || entry.QType == survey.MC) && (len(entry.UsedOptions) > 0) {
-        fmt.Println("    Used options:")
+        switch {
+        case entry.Ordinal:
+            fmt.Println("    Options (ordinal):")
+        case len(entry.Options) > 0:
+            fmt.Println("    Options:")
+        default:
+            fmt.Println("    Used options:")
+        }
         for _, opt := range entry.UsedOptions {
-            fmt.Printf("        - %s\n", opt)
+            if entry.Declared(opt) {
+                fmt.Printf("        - %s\n", opt)
+            } else {
+                fmt.Printf("        - %s (not declared)\n", opt)
+            }
         }
     }
 }
 
+// blockGroup is a block and its questions, see groupByBlock.
+type blockGroup struct {
+    name    string
+    entries []*survey.SchemaEntry
+}
+
+// groupByBlock groups schema entries by their block, in the order of each
+// block's first question.
+func groupByBlock(entries []*survey.SchemaEntry) []blockGroup {
+    var groups []blockGroup
+    for _, entry := range entries {
+        i := slices.IndexFunc(groups, func(g blockGroup) bool { return g.name == entry.Block })
+        if i < 0 {
+            i = len(groups)
+            groups = append(groups, blockGroup{name: entry.Block})
+        }
+        groups[i].entries = append(groups[i].entries, entry)
+    }
+    return groups
+}
+
+// outputBlockHeading prints the heading of a block group. Questions
+// without a block are listed under "Other questions".
+func outputBlockHeading(name string) {
+    if name == "" {
+        name = "Other questions"
+    }
+    fmt.Printf("== %s ==\n", name)
+}
+
+// outputSchemaEntries prints numbered schema entries, grouped by block if
+// the schema has blocks.
 func outputSchemaEntries(entries []*survey.SchemaEntry) {
     i := 1
     width := len(fmt.Sprintf("%d", len(entries)))
-    for _, entry := range entries {
-        outputSchemaEntry(entry, i, width)
-        i++
+    groups := groupByBlock(entries)
+    for _, g := range groups {
+        if len(groups) > 1 || g.name != "" {
+            outputBlockHeading(g.name)
+        }
+        for _, entry := range g.entries {
+            outputSchemaEntry(entry, i, width)
+            i++
+        }
     }
 }
 
@@ -66,7 +128,7 @@ func outputResponseValue(entry *survey.SchemaEntry, resp survey.Response) {
         } else {
             fmt.Printf("    %s: (invalid)\n", entry.Key)
         }
-    case survey.TE:
+    case survey.TE, survey.NUM:
         s, ok := val.AsString()
         if ok {
             fmt.Printf("    %s: %s\n", entry.Key, s)
@@ -83,13 +145,71 @@ func outputResponse(schema survey.Schema, resp survey.Response, keys []string) {
         if len(keys) > 0 && !slices.Contains(keys, entry.Key) {
             continue
         }
+        // A matrix has no answers of its own, its rows are printed instead
+        if entry.QType == survey.MATRIX {
+            continue
+        }
         outputResponseValue(entry, resp)
     }
 }
 
-func outputResponses(schema survey.Schema, responses []survey.Response, keys []string) {
-    for i, resp := range responses {
-        fmt.Printf("Response %d:\n", i+1)
-        outputResponse(schema, resp, keys)
+// responseLabel names a response by its row number, starting at 1, and
+// its respondent ID if the data has an ID question.
+func responseLabel(data *survey.SurveyData, row int) string {
+    if data.IDKey == "" {
+        return fmt.Sprintf("Response %d", row+1)
+    }
+    return fmt.Sprintf("Response %d (%s %s)", row+1, data.IDKey, data.ID(row))
+}
+
+// QueryOptions controls how ResponseQuery arguments are checked against
+// the schema, see survey.QueryOptions.
+var QueryOptions survey.QueryOptions
+
+// parseQuery parses a ResponseQuery argument, checks it against the schema
+// and echoes the query in canonical form, so that users see how it was
+// read.
+func parseQuery(data *survey.SurveyData, arg string) (*survey.ResponseQuery, error) {
+    query, err := checkQuery(data.Schema, arg)
+    if err != nil {
+        return nil, err
+    }
+    if s := query.String(); s != "" {
+        fmt.Printf("Query: %s\n", s)
+    }
+    return query, nil
+}
+
+// checkQuery parses a ResponseQuery argument and checks it against the
+// schema, without echoing it.
+func checkQuery(schema survey.Schema, arg string) (*survey.ResponseQuery, error) {
+    query, err := survey.ParseResponseQuery(arg)
+    if err != nil {
+        return nil, err
+    }
+    if err := query.Validate(schema, QueryOptions); err != nil {
+        return nil, err
+    }
+    return query, nil
+}
+
+// outputResolvedKeys shows the keys a query's key selectors expanded to,
+// unless the selectors are plain keys. Long lists are cut short.
+func outputResolvedKeys(selectors, keys []string) {
+    if slices.Equal(selectors, keys) {
+        return
+    }
+    const maxShown = 20
+    list := strings.Join(keys[:min(len(keys), maxShown)], ", ")
+    if len(keys) > maxShown {
+        list += fmt.Sprintf(" and %d more", len(keys)-maxShown)
+    }
+    fmt.Printf("Keys (%d): %s\n\n", len(keys), list)
+}
+
+func outputResponses(data *survey.SurveyData, rows []int, keys []string) {
+    for _, row := range rows {
+        fmt.Printf("%s:\n", responseLabel(data, row))
+        outputResponse(data.Schema, data.Response(row), keys)
     }
 }
@@ -0,0 +1,39 @@
+package cli
+
+import (
+    "fmt"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// ValidateCommand prints the diagnostics of the loaded data.
+type ValidateCommand struct{}
+
+func (c *ValidateCommand) Name() string { return "validate" }
+
+func (c *ValidateCommand) Aliases() []string { return []string{"check", "diagnostics"} }
+
+func (c *ValidateCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    diags := data.Validate()
+    if len(args) > 0 {
+        if _, ok := data.Schema.Get(args[0]); !ok {
+            return true, fmt.Errorf("question %q not found", args[0])
+        }
+        filtered := diags[:0]
+        for _, d := range diags {
+            if d.Key == args[0] {
+                filtered = append(filtered, d)
+            }
+        }
+        diags = filtered
+    }
+
+    counts := make(map[survey.Severity]int)
+    for _, d := range diags {
+        counts[d.Severity]++
+        fmt.Printf("  %s\n", d)
+    }
+    fmt.Printf("%d error(s), %d warning(s), %d note(s)\n",
+        counts[survey.SeverityError], counts[survey.SeverityWarning], counts[survey.SeverityInfo])
+    return true, nil
+}
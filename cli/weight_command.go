@@ -0,0 +1,49 @@
+package cli
+
+import (
+    "fmt"
+    "os"
+    "slices"
+
+    "srg.de/jb/air_task3/survey"
+)
+
+// WeightCommand shows, sets or removes the respondent weights.
+type WeightCommand struct{}
+
+func (c *WeightCommand) Name() string { return "weight" }
+
+func (c *WeightCommand) Aliases() []string { return []string{"weights"} }
+
+// ApplyWeights sets the respondent weights from a weight specification:
+// "off" removes them, the name of an existing file loads them from that
+// file (keyed by the respondent IDs), anything else names a numeric
+// question holding the weights.
+func ApplyWeights(data *survey.SurveyData, spec string) error {
+    if spec == "off" {
+        return data.SetWeights(nil, "")
+    }
+    if _, err := os.Stat(spec); err == nil {
+        return data.LoadWeights(spec)
+    }
+    return data.UseWeightColumn(spec)
+}
+
+func (c *WeightCommand) Run(cmd string, args []string, data *survey.SurveyData) (bool, error) {
+    if len(args) > 0 {
+        if err := ApplyWeights(data, args[0]); err != nil {
+            return true, err
+        }
+    }
+    if !data.Weighted() {
+        fmt.Println("Responses are unweighted.")
+        return true, nil
+    }
+    weights := data.Weights()
+    fmt.Printf("Responses are weighted by %s.\n", data.WeightSource)
+    if len(weights) > 0 {
+        fmt.Printf("  n: %d, sum of weights: %.1f, min: %.3f, max: %.3f\n",
+            len(weights), data.WeightedCount(data.Rows()), slices.Min(weights), slices.Max(weights))
+    }
+    return true, nil
+}
@@ -2,10 +2,13 @@ module srg.de/jb/air_task3
 
 go 1.24.4
 
-require github.com/xuri/excelize/v2 v2.9.1
+require (
+	github.com/chzyer/readline v1.5.1
+	github.com/xuri/excelize/v2 v2.9.1
+	golang.org/x/text v0.25.0
+)
 
 require (
-	github.com/chzyer/readline v1.5.1 // indirect
 	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.4 // indirect
 	github.com/tiendc/go-deepcopy v1.6.0 // indirect
@@ -14,5 +17,4 @@ require (
 	golang.org/x/crypto v0.38.0 // indirect
 	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.25.0 // indirect
 )
@@ -2,6 +2,7 @@ package main
 
 import (
     "errors"
+    "flag"
     "fmt"
     "io"
     "os"
@@ -14,17 +15,95 @@ import (
     "srg.de/jb/air_task3/survey"
 )
 
+// printProgress draws a single-line progress bar for the initial load.
+func printProgress(done, total int) {
+    const width = 30
+    if total <= 0 {
+        fmt.Printf("\r  %d rows", done)
+        return
+    }
+    filled := done * width / total
+    fmt.Printf("\r  [%s%s] %3d%% (%d/%d rows)",
+        strings.Repeat("#", filled), strings.Repeat(".", width-filled), done*100/total, done, total)
+    if done >= total {
+        fmt.Println()
+    }
+}
+
 func main() {
-    xlsxFile := "so_2024_raw.xlsx"
+    format := flag.String("format", "", "input format ("+strings.Join(survey.SourceFormats(), ", ")+"), picked by file extension if empty")
+    rules := flag.String("rules", "", "normalization rules file (default <data file>_rules.csv if it exists)")
+    project := flag.String("project", "", "project file with derived questions (default <data file>_project.json)")
+    weight := flag.String("weight", "", "respondent weights: a numeric question or a CSV file of id,weight")
+    idKey := flag.String("id", "", "question holding the respondent IDs (default ResponseId if present)")
+    foldKeys := flag.Bool("ignore-key-case", false, "match question keys in queries ignoring case")
+    flag.Usage = func() {
+        fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [data file]\n", os.Args[0])
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    dataFile := "so_2024_raw.xlsx"
+    if flag.NArg() > 0 {
+        dataFile = flag.Arg(0)
+    }
+
+    // Use the default rules file only if it exists, but record merges
+    // accepted by the normalize command in it either way
+    rulesFile := *rules
+    if rulesFile == "" {
+        rulesFile = survey.DefaultRulesFilename(dataFile)
+        if _, err := os.Stat(rulesFile); err == nil {
+            *rules = rulesFile
+        }
+    }
 
-    fmt.Printf("Loading survey data from %s...\n", xlsxFile)
+    fmt.Printf("Loading survey data from %s...\n", dataFile)
     start := time.Now()
-    data, err := survey.ReadSurveyDataCached(xlsxFile)
+    data, err := survey.ReadSurveyDataCachedWithOptions(dataFile, survey.ReadOptions{
+        Progress:  printProgress,
+        Format:    *format,
+        RulesFile: *rules,
+    })
     if err != nil {
         fmt.Fprintf(os.Stderr, "Error: %v\n", err)
         os.Exit(1)
     }
-    fmt.Printf("Loaded survey data in %s\n", time.Since(start))
+    data.RulesFile = rulesFile
+
+    projectFile := *project
+    if projectFile == "" {
+        projectFile = survey.DefaultProjectFilename(dataFile)
+    }
+    proj, err := survey.LoadProject(projectFile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        os.Exit(1)
+    }
+    if err := data.ApplyProject(proj); err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: some derived questions could not be computed:\n%v\n", err)
+    }
+    if data.LoadedFrom != dataFile {
+        fmt.Printf("Loaded survey data from cache %s in %s\n", data.LoadedFrom, time.Since(start))
+    } else {
+        fmt.Printf("Loaded survey data from %s in %s (cache rebuilt)\n", data.LoadedFrom, time.Since(start))
+    }
+
+    if *idKey != "" {
+        if err := data.SetIDKey(*idKey); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            os.Exit(1)
+        }
+    }
+
+    if *weight != "" {
+        if err := cli.ApplyWeights(data, *weight); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            os.Exit(1)
+        }
+        fmt.Printf("Responses are weighted by %s\n", data.WeightSource)
+    }
+
+    cli.QueryOptions.FoldKeyCase = *foldKeys
 
     commandSet, err := cli.InitCommands()
     if err != nil {
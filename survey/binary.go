@@ -0,0 +1,407 @@
+package survey
+
+import (
+    "bufio"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "math"
+    "os"
+)
+
+// The binary cache format consists of
+//
+//   - the magic string "SVYB" and the cache format version (uint32),
+//   - the cache header and the schema, both as length-prefixed JSON,
+//   - the number of responses (uvarint),
+//   - a column directory with key, block length and CRC-32 of each column,
+//   - the column blocks in directory order.
+//
+// All integers are little endian or uvarint encoded. Column blocks are
+// decoded on first access only, so columns no command touches never cost
+// more than the bytes read from disk.
+const binaryMagic = "SVYB"
+
+const binaryExt = ".bin"
+
+const maxHeaderSize = 1 << 20
+
+func isBinaryFile(filename string) bool {
+    return len(filename) > len(binaryExt) && filename[len(filename)-len(binaryExt):] == binaryExt
+}
+
+func appendBlock(buf []byte, block []byte) []byte {
+    buf = binary.AppendUvarint(buf, uint64(len(block)))
+    return append(buf, block...)
+}
+
+// encodeColumn encodes a column as a presence bitmap followed by the
+// values of all present rows.
+func encodeColumn(c *Column, n int) []byte {
+    c.ensureLoaded()
+    buf := make([]byte, (n+7)/8)
+    for row := 0; row < n; row++ {
+        if c.present[row] {
+            buf[row/8] |= 1 << (row % 8)
+        }
+    }
+    for row := 0; row < n; row++ {
+        if !c.present[row] {
+            continue
+        }
+        switch c.Entry.QType {
+        case SC:
+            buf = binary.AppendUvarint(buf, uint64(c.codes[row]))
+        case MC:
+            codes := c.codes[c.offsets[row]:c.offsets[row+1]]
+            buf = binary.AppendUvarint(buf, uint64(len(codes)))
+            for _, code := range codes {
+                buf = binary.AppendUvarint(buf, uint64(code))
+            }
+        case TE:
+            buf = appendBlock(buf, []byte(c.texts[row]))
+        case NUM:
+            buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.nums[row]))
+        }
+    }
+    return buf
+}
+
+func (sd *SurveyData) WriteBinary(w io.Writer) error {
+    var header []byte
+    if h := sd.cacheHeader(); h != nil {
+        var err error
+        header, err = json.Marshal(h)
+        if err != nil {
+            return err
+        }
+    }
+    schema, err := json.Marshal(sd.Schema)
+    if err != nil {
+        return err
+    }
+
+    blocks := make([][]byte, len(sd.Schema))
+    for i, entry := range sd.Schema {
+        col, ok := sd.columns[entry.Key]
+        if !ok {
+            return fmt.Errorf("no column for %q", entry.Key)
+        }
+        blocks[i] = encodeColumn(col, sd.n)
+    }
+
+    buf := []byte(binaryMagic)
+    buf = binary.LittleEndian.AppendUint32(buf, cacheFormatVersion)
+    buf = appendBlock(buf, header)
+    buf = appendBlock(buf, schema)
+    buf = binary.AppendUvarint(buf, uint64(sd.n))
+    buf = binary.AppendUvarint(buf, uint64(len(blocks)))
+    for i, entry := range sd.Schema {
+        buf = appendBlock(buf, []byte(entry.Key))
+        buf = binary.AppendUvarint(buf, uint64(len(blocks[i])))
+        buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(blocks[i]))
+    }
+    if _, err := w.Write(buf); err != nil {
+        return err
+    }
+    for _, block := range blocks {
+        if _, err := w.Write(block); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (sd *SurveyData) WriteBinaryToFile(filename string) error {
+    f, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    bw := bufio.NewWriter(f)
+    if err := sd.WriteBinary(bw); err != nil {
+        f.Close()
+        return err
+    }
+    if err := bw.Flush(); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// binReader decodes the primitives of the binary format from a buffer.
+// The first error sticks and makes all further reads return zero values.
+type binReader struct {
+    buf []byte
+    pos int
+    err error
+}
+
+var errTruncated = errors.New("unexpected end of data")
+
+func (r *binReader) bytes(n int) []byte {
+    if r.err != nil {
+        return nil
+    }
+    if n < 0 || n > len(r.buf)-r.pos {
+        r.err = errTruncated
+        return nil
+    }
+    b := r.buf[r.pos : r.pos+n]
+    r.pos += n
+    return b
+}
+
+func (r *binReader) uvarint() uint64 {
+    if r.err != nil {
+        return 0
+    }
+    v, n := binary.Uvarint(r.buf[r.pos:])
+    if n <= 0 {
+        r.err = errTruncated
+        return 0
+    }
+    r.pos += n
+    return v
+}
+
+func (r *binReader) uint32() uint32 {
+    b := r.bytes(4)
+    if b == nil {
+        return 0
+    }
+    return binary.LittleEndian.Uint32(b)
+}
+
+func (r *binReader) uint64() uint64 {
+    b := r.bytes(8)
+    if b == nil {
+        return 0
+    }
+    return binary.LittleEndian.Uint64(b)
+}
+
+func (r *binReader) block() []byte {
+    n := r.uvarint()
+    if n > uint64(len(r.buf)) {
+        r.err = errTruncated
+        return nil
+    }
+    return r.bytes(int(n))
+}
+
+// decodeColumn fills c from a block written by encodeColumn. If c is nil,
+// the block is only checked: it must hold exactly n rows of entry's type
+// with option codes that index entry.UsedOptions.
+func decodeColumn(c *Column, entry *SchemaEntry, n int, block []byte) error {
+    r := &binReader{buf: block}
+    bitmap := r.bytes((n + 7) / 8)
+    if r.err != nil {
+        return r.err
+    }
+    optionCode := func() int32 {
+        code := r.uvarint()
+        if r.err == nil && code >= uint64(len(entry.UsedOptions)) {
+            r.err = fmt.Errorf("option code %d out of range", code)
+        }
+        return int32(code)
+    }
+    if c != nil {
+        c.present = make([]bool, n)
+        switch entry.QType {
+        case SC:
+            c.codes = make([]int32, n)
+        case MC:
+            c.offsets = make([]int32, n+1)
+        case TE:
+            c.texts = make([]string, n)
+        case NUM:
+            c.nums = make([]float64, n)
+        }
+    }
+    for row := 0; row < n; row++ {
+        present := bitmap[row/8]&(1<<(row%8)) != 0
+        if !present {
+            if c != nil && entry.QType == MC {
+                c.offsets[row+1] = int32(len(c.codes))
+            }
+            continue
+        }
+        switch entry.QType {
+        case SC:
+            code := optionCode()
+            if c != nil {
+                c.codes[row] = code
+            }
+        case MC:
+            count := r.uvarint()
+            if count > uint64(len(r.buf)-r.pos) {
+                r.err = errTruncated
+            }
+            for i := uint64(0); i < count && r.err == nil; i++ {
+                code := optionCode()
+                if c != nil {
+                    c.codes = append(c.codes, code)
+                }
+            }
+            if c != nil {
+                c.offsets[row+1] = int32(len(c.codes))
+            }
+        case TE:
+            text := r.block()
+            if c != nil {
+                c.texts[row] = string(text)
+            }
+        case NUM:
+            bits := r.uint64()
+            if c != nil {
+                c.nums[row] = math.Float64frombits(bits)
+            }
+        default:
+            return fmt.Errorf("unsupported question type %s", entry.QType)
+        }
+        if r.err != nil {
+            return fmt.Errorf("row %d: %w", row, r.err)
+        }
+        if c != nil {
+            c.present[row] = true
+        }
+    }
+    if r.pos != len(block) {
+        return errors.New("unexpected data after the last row")
+    }
+    return nil
+}
+
+// LoadSurveyDataBinary reads survey data written by WriteBinary. Column
+// blocks are verified against their checksums and checked by decodeColumn,
+// but only decoded into columns when first accessed.
+func LoadSurveyDataBinary(data []byte) (*SurveyData, error) {
+    header, r, err := readBinaryPreamble(data)
+    if err != nil {
+        return nil, err
+    }
+    var schema Schema
+    if err := json.Unmarshal(r.block(), &schema); err != nil && r.err == nil {
+        return nil, fmt.Errorf("schema: %w", err)
+    }
+    n := r.uvarint()
+    ncols := r.uvarint()
+    if r.err != nil {
+        return nil, r.err
+    }
+    if n > uint64(len(data))*8 || ncols > uint64(len(data)) {
+        return nil, errors.New("invalid column directory")
+    }
+
+    type dirEntry struct {
+        key    string
+        length uint64
+        crc    uint32
+    }
+    dir := make([]dirEntry, ncols)
+    for i := range dir {
+        dir[i] = dirEntry{key: string(r.block()), length: r.uvarint(), crc: r.uint32()}
+    }
+    if r.err != nil {
+        return nil, fmt.Errorf("column directory: %w", r.err)
+    }
+
+    sd := &SurveyData{
+        Schema:  schema,
+        IDKey:   DetectIDKey(schema),
+        columns: make(map[string]*Column, len(schema)),
+        n:       int(n),
+    }
+    sd.applyCacheHeader(header)
+    for _, de := range dir {
+        if de.length > uint64(len(data)) {
+            return nil, fmt.Errorf("column %q: %w", de.key, errTruncated)
+        }
+        block := r.bytes(int(de.length))
+        if r.err != nil {
+            return nil, fmt.Errorf("column %q: %w", de.key, r.err)
+        }
+        if crc32.ChecksumIEEE(block) != de.crc {
+            return nil, fmt.Errorf("column %q: checksum mismatch", de.key)
+        }
+        entry, ok := schema.Get(de.key)
+        if !ok {
+            return nil, fmt.Errorf("column %q not in schema", de.key)
+        }
+        if err := decodeColumn(nil, entry, sd.n, block); err != nil {
+            return nil, fmt.Errorf("column %q: %w", de.key, err)
+        }
+        col := &Column{Entry: entry}
+        // The block was checked above, so decoding it can't fail
+        col.load = func(c *Column) { decodeColumn(c, c.Entry, sd.n, block) }
+        sd.columns[de.key] = col
+    }
+    for _, entry := range schema {
+        if _, ok := sd.columns[entry.Key]; !ok {
+            return nil, fmt.Errorf("missing column %q", entry.Key)
+        }
+    }
+    return sd, nil
+}
+
+// readBinaryPreamble checks magic and version and decodes the cache header.
+func readBinaryPreamble(data []byte) (*CacheHeader, *binReader, error) {
+    r := &binReader{buf: data}
+    if string(r.bytes(len(binaryMagic))) != binaryMagic {
+        return nil, nil, errors.New("not a binary survey cache")
+    }
+    if version := r.uint32(); version != cacheFormatVersion {
+        return nil, nil, fmt.Errorf("cache format version %d, want %d", version, cacheFormatVersion)
+    }
+    raw := r.block()
+    if r.err != nil {
+        return nil, nil, r.err
+    }
+    if len(raw) == 0 {
+        return nil, r, nil
+    }
+    var header CacheHeader
+    if err := json.Unmarshal(raw, &header); err != nil {
+        return nil, nil, fmt.Errorf("header: %w", err)
+    }
+    return &header, r, nil
+}
+
+// readBinaryCacheHeader reads only the header of a binary cache file.
+func readBinaryCacheHeader(filename string) (*CacheHeader, error) {
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    br := bufio.NewReader(f)
+    prefix := make([]byte, len(binaryMagic)+4)
+    if _, err := io.ReadFull(br, prefix); err != nil {
+        return nil, err
+    }
+    length, err := binary.ReadUvarint(br)
+    if err != nil {
+        return nil, err
+    }
+    if length > maxHeaderSize {
+        return nil, errors.New("invalid cache header")
+    }
+    raw := make([]byte, length)
+    if _, err := io.ReadFull(br, raw); err != nil {
+        return nil, err
+    }
+    data := binary.AppendUvarint(prefix, length)
+    data = append(data, raw...)
+    header, _, err := readBinaryPreamble(data)
+    if err != nil {
+        return nil, err
+    }
+    if header == nil {
+        return nil, errors.New("cache has no header")
+    }
+    return header, nil
+}
@@ -0,0 +1,142 @@
+package survey
+
+import (
+    "bytes"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func newBinaryTestData() *SurveyData {
+    return NewSurveyData(
+        Schema{
+            {Key: "Q1", Text: "Favorite color", QType: SC},
+            {Key: "Q2", Text: "Languages", QType: MC},
+            {Key: "Q3", Text: "Comment", QType: TE},
+            {Key: "Q4", Text: "Years", QType: NUM},
+        },
+        []Response{
+            {"Q1": {Val: "red"}, "Q2": {Val: []string{"Python", "Go"}}, "Q3": {Val: "Nice!"}, "Q4": {Val: 3.5}},
+            {"Q1": {Val: "blue"}, "Q2": {Val: []string{}}, "Q3": {Val: ""}, "Q4": {Val: 0.0}},
+            {"Q1": {Val: nil}, "Q2": {Val: nil}, "Q3": {Val: nil}, "Q4": {Val: nil}},
+        },
+    )
+}
+
+func TestSurveyData_BinaryRoundTrip(t *testing.T) {
+    sd := newBinaryTestData()
+    sd.Sources = []SourceInfo{{Path: "test.xlsx", Size: 42, SHA256: "abc"}}
+
+    var buf bytes.Buffer
+    if err := sd.WriteBinary(&buf); err != nil {
+        t.Fatalf("WriteBinary failed: %v", err)
+    }
+    loaded, err := LoadSurveyDataBinary(buf.Bytes())
+    if err != nil {
+        t.Fatalf("LoadSurveyDataBinary failed: %v", err)
+    }
+
+    if loaded.Len() != sd.Len() {
+        t.Fatalf("Len() = %d, want %d", loaded.Len(), sd.Len())
+    }
+    if !reflect.DeepEqual(loaded.Sources, sd.Sources) {
+        t.Errorf("Sources = %+v, want %+v", loaded.Sources, sd.Sources)
+    }
+    for row := 0; row < sd.Len(); row++ {
+        if got, want := loaded.Response(row), sd.Response(row); !reflect.DeepEqual(got, want) {
+            t.Errorf("response %d = %#v, want %#v", row, got, want)
+        }
+    }
+}
+
+func TestSurveyData_BinaryLazyColumns(t *testing.T) {
+    var buf bytes.Buffer
+    if err := newBinaryTestData().WriteBinary(&buf); err != nil {
+        t.Fatalf("WriteBinary failed: %v", err)
+    }
+    loaded, err := LoadSurveyDataBinary(buf.Bytes())
+    if err != nil {
+        t.Fatalf("LoadSurveyDataBinary failed: %v", err)
+    }
+
+    col, _ := loaded.Column("Q3")
+    if col.texts != nil {
+        t.Fatal("TE column decoded before first access")
+    }
+    if got := loaded.Value(0, "Q3"); got.Val != "Nice!" {
+        t.Errorf("Value(0, Q3) = %#v, want \"Nice!\"", got.Val)
+    }
+    if col.texts == nil {
+        t.Error("TE column not decoded after access")
+    }
+    if other, _ := loaded.Column("Q2"); other.offsets != nil {
+        t.Error("accessing one column decoded another")
+    }
+}
+
+func TestLoadSurveyDataBinary_Corrupt(t *testing.T) {
+    var buf bytes.Buffer
+    if err := newBinaryTestData().WriteBinary(&buf); err != nil {
+        t.Fatalf("WriteBinary failed: %v", err)
+    }
+    data := buf.Bytes()
+
+    flipped := bytes.Clone(data)
+    flipped[len(flipped)-1] ^= 0xff
+    if _, err := LoadSurveyDataBinary(flipped); err == nil {
+        t.Error("expected checksum error for corrupted column block")
+    }
+    if _, err := LoadSurveyDataBinary(data[:len(data)-3]); err == nil {
+        t.Error("expected error for truncated file")
+    }
+    if _, err := LoadSurveyDataBinary([]byte("{}")); err == nil {
+        t.Error("expected error for non-binary input")
+    }
+
+    // Blocks that pass the checksum but don't fit the schema fail on load,
+    // not on first access
+    sd := newBinaryTestData()
+    entry, _ := sd.Schema.Get("Q1")
+    entry.UsedOptions = entry.UsedOptions[:1]
+    buf.Reset()
+    if err := sd.WriteBinary(&buf); err != nil {
+        t.Fatalf("WriteBinary failed: %v", err)
+    }
+    if _, err := LoadSurveyDataBinary(buf.Bytes()); err == nil {
+        t.Error("expected error for out of range option code")
+    }
+}
+
+func TestLoadSurveyDataFromFile_Binary(t *testing.T) {
+    fresh, err := ReadSurveyData(filepath.Join(".", "so_test.xlsx"))
+    if err != nil {
+        t.Fatalf("failed to read test data: %v", err)
+    }
+    binFile := filepath.Join(t.TempDir(), "test.cache.bin")
+    if err := fresh.WriteToFile(binFile); err != nil {
+        t.Fatalf("WriteToFile failed: %v", err)
+    }
+    loaded, err := LoadSurveyDataFromFile(binFile)
+    if err != nil {
+        t.Fatalf("LoadSurveyDataFromFile failed: %v", err)
+    }
+    if loaded.LoadedFrom != binFile {
+        t.Errorf("LoadedFrom = %q, want %q", loaded.LoadedFrom, binFile)
+    }
+    if loaded.Len() != fresh.Len() {
+        t.Fatalf("Len() = %d, want %d", loaded.Len(), fresh.Len())
+    }
+    for row := 0; row < fresh.Len(); row++ {
+        if !reflect.DeepEqual(loaded.Response(row), fresh.Response(row)) {
+            t.Errorf("binary response %d differs from fresh response", row)
+        }
+    }
+
+    header, err := readCacheHeader(binFile)
+    if err != nil {
+        t.Fatalf("readCacheHeader failed: %v", err)
+    }
+    if header.mismatch(fresh.Sources) != "" {
+        t.Errorf("header mismatch: %s", header.mismatch(fresh.Sources))
+    }
+}
@@ -0,0 +1,158 @@
+package survey
+
+import (
+    "compress/gzip"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// cacheFormatVersion must be increased whenever the layout of the cache
+// files or the way values are read changes, so that existing caches are
+// rebuilt.
+const cacheFormatVersion = 7
+
+// SourceInfo identifies the contents of the file survey data was read from.
+type SourceInfo struct {
+    Path    string
+    Size    int64
+    ModTime time.Time
+    SHA256  string
+}
+
+// DescribeSource stats and hashes the given file.
+func DescribeSource(filename string) (*SourceInfo, error) {
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    stat, err := f.Stat()
+    if err != nil {
+        return nil, err
+    }
+    h := sha256.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return nil, fmt.Errorf("failed to hash %s: %w", filename, err)
+    }
+    return &SourceInfo{
+        Path:    filename,
+        Size:    stat.Size(),
+        ModTime: stat.ModTime().UTC(),
+        SHA256:  hex.EncodeToString(h.Sum(nil)),
+    }, nil
+}
+
+func describeSources(filenames []string) ([]SourceInfo, error) {
+    var out []SourceInfo
+    for _, filename := range filenames {
+        src, err := DescribeSource(filename)
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, *src)
+    }
+    return out, nil
+}
+
+// CacheHeader is stored at the start of a cache file.
+type CacheHeader struct {
+    Version     int
+    Sources     []SourceInfo
+    Diagnostics []Diagnostic `json:",omitempty"`
+}
+
+// cacheHeader returns the header describing where the data came from, or
+// nil if its sources aren't known.
+func (sd *SurveyData) cacheHeader() *CacheHeader {
+    if sd.Sources == nil {
+        return nil
+    }
+    return &CacheHeader{Version: cacheFormatVersion, Sources: sd.Sources, Diagnostics: sd.Diagnostics}
+}
+
+// applyCacheHeader restores the information of a cache header.
+func (sd *SurveyData) applyCacheHeader(h *CacheHeader) {
+    if h != nil {
+        sd.Sources = h.Sources
+        sd.Diagnostics = h.Diagnostics
+    }
+}
+
+// mismatch returns why a cache with this header can't be used for the
+// given source files, or an empty string if it can.
+func (h *CacheHeader) mismatch(srcs []SourceInfo) string {
+    if h.Version != cacheFormatVersion {
+        return fmt.Sprintf("cache format version %d, want %d", h.Version, cacheFormatVersion)
+    }
+    if len(h.Sources) != len(srcs) {
+        return "number of source files changed"
+    }
+    for i, src := range srcs {
+        cached := h.Sources[i]
+        switch {
+        case !samePath(cached.Path, src.Path):
+            return fmt.Sprintf("cache was written for %s, not %s", cached.Path, src.Path)
+        case cached.Size != src.Size:
+            return fmt.Sprintf("size of %s changed", src.Path)
+        case !cached.ModTime.Equal(src.ModTime):
+            return fmt.Sprintf("modification time of %s changed", src.Path)
+        case cached.SHA256 != src.SHA256:
+            return fmt.Sprintf("content of %s changed", src.Path)
+        }
+    }
+    return ""
+}
+
+// samePath reports whether two paths name the same file location.
+func samePath(a, b string) bool {
+    absA, errA := filepath.Abs(a)
+    absB, errB := filepath.Abs(b)
+    if errA != nil || errB != nil {
+        return filepath.Clean(a) == filepath.Clean(b)
+    }
+    return absA == absB
+}
+
+// readCacheHeader reads only the header of a binary or JSON cache file.
+func readCacheHeader(filename string) (*CacheHeader, error) {
+    if isBinaryFile(filename) {
+        return readBinaryCacheHeader(filename)
+    }
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    var r io.Reader = f
+    if strings.HasSuffix(filename, ".gz") {
+        gr, err := gzip.NewReader(f)
+        if err != nil {
+            return nil, err
+        }
+        defer gr.Close()
+        r = gr
+    }
+    dec := json.NewDecoder(r)
+    if err := expectDelim(dec, '{'); err != nil {
+        return nil, err
+    }
+    tok, err := dec.Token()
+    if err != nil {
+        return nil, err
+    }
+    if tok != "Header" {
+        return nil, fmt.Errorf("cache has no header")
+    }
+    var h CacheHeader
+    if err := dec.Decode(&h); err != nil {
+        return nil, fmt.Errorf("header: %w", err)
+    }
+    return &h, nil
+}
@@ -0,0 +1,248 @@
+package survey
+
+import (
+    "fmt"
+    "math"
+    "sync"
+)
+
+// Column stores the values of a single SchemaEntry for all responses.
+// SC and MC values are dictionary encoded: each option is stored as its
+// index into Entry.UsedOptions.
+type Column struct {
+    Entry   *SchemaEntry
+    present []bool
+    codes   []int32   // SC: one code per row; MC: codes of all rows back to back
+    offsets []int32   // MC: codes[offsets[i]:offsets[i+1]] belong to row i
+    texts   []string  // TE
+    nums    []float64 // NUM
+
+    // load decodes the column on first access if it was read lazily
+    load     func(*Column)
+    loadOnce sync.Once
+}
+
+func (c *Column) ensureLoaded() {
+    if c.load != nil {
+        c.loadOnce.Do(func() { c.load(c) })
+    }
+}
+
+func newColumn(entry *SchemaEntry) *Column {
+    c := &Column{Entry: entry}
+    if entry.QType == MC {
+        c.offsets = []int32{0}
+    }
+    return c
+}
+
+func (c *Column) Len() int {
+    c.ensureLoaded()
+    return len(c.present)
+}
+
+func (c *Column) Present(row int) bool {
+    c.ensureLoaded()
+    return c.present[row]
+}
+
+// Code returns the option code of an SC value, or -1 if the value is
+// missing or the column is not single choice.
+func (c *Column) Code(row int) int {
+    c.ensureLoaded()
+    if c.Entry.QType != SC || !c.present[row] {
+        return -1
+    }
+    return int(c.codes[row])
+}
+
+// Codes returns the option codes of an MC value. The returned slice shares
+// memory with the column and must not be modified.
+func (c *Column) Codes(row int) []int32 {
+    c.ensureLoaded()
+    if c.Entry.QType != MC || !c.present[row] {
+        return nil
+    }
+    return c.codes[c.offsets[row]:c.offsets[row+1]]
+}
+
+func (c *Column) Float(row int) (float64, bool) {
+    c.ensureLoaded()
+    if c.Entry.QType != NUM || !c.present[row] {
+        return 0, false
+    }
+    return c.nums[row], true
+}
+
+// Value decodes the value of the given row into a ResponseValue.
+func (c *Column) Value(row int) ResponseValue {
+    c.ensureLoaded()
+    if !c.present[row] {
+        return ResponseValue{Val: nil}
+    }
+    switch c.Entry.QType {
+    case SC:
+        return ResponseValue{Val: c.Entry.UsedOptions[c.codes[row]]}
+    case MC:
+        codes := c.Codes(row)
+        vals := make([]string, len(codes))
+        for i, code := range codes {
+            vals[i] = c.Entry.UsedOptions[code]
+        }
+        return ResponseValue{Val: vals}
+    case TE:
+        return ResponseValue{Val: c.texts[row]}
+    case NUM:
+        return ResponseValue{Val: c.nums[row]}
+    default:
+        return ResponseValue{Val: nil}
+    }
+}
+
+// columnBuilder appends values to a Column. Option codes are assigned in
+// order of first appearance while building and remapped to indices into
+// Entry.UsedOptions by finish.
+type columnBuilder struct {
+    col  *Column
+    dict map[string]int32
+    opts []string
+}
+
+func newColumnBuilder(entry *SchemaEntry) *columnBuilder {
+    return &columnBuilder{
+        col:  newColumn(entry),
+        dict: make(map[string]int32),
+    }
+}
+
+func (b *columnBuilder) code(opt string) int32 {
+    code, ok := b.dict[opt]
+    if !ok {
+        code = int32(len(b.opts))
+        b.dict[opt] = code
+        b.opts = append(b.opts, opt)
+    }
+    return code
+}
+
+func (b *columnBuilder) append(val ResponseValue) {
+    c := b.col
+    present := false
+    switch c.Entry.QType {
+    case SC:
+        var code int32
+        if s, ok := val.AsString(); ok && s != "" {
+            code = b.code(s)
+            present = true
+        }
+        c.codes = append(c.codes, code)
+    case MC:
+        if ss, ok := val.AsStringSlice(); ok {
+            for _, opt := range ss {
+                if opt != "" {
+                    c.codes = append(c.codes, b.code(opt))
+                }
+            }
+            present = true
+        }
+        c.offsets = append(c.offsets, int32(len(c.codes)))
+    case TE:
+        s, ok := val.AsString()
+        c.texts = append(c.texts, s)
+        present = ok
+    case NUM:
+        f, ok := val.AsFloat()
+        c.nums = append(c.nums, f)
+        present = ok
+    }
+    c.present = append(c.present, present)
+}
+
+func (b *columnBuilder) finish() *Column {
+    c := b.col
+    if len(b.opts) == 0 {
+        return c
+    }
+    c.Entry.addUsedOptions(b.opts)
+    index := make(map[string]int32, len(c.Entry.UsedOptions))
+    for i, opt := range c.Entry.UsedOptions {
+        index[opt] = int32(i)
+    }
+    remap := make([]int32, len(b.opts))
+    for i, opt := range b.opts {
+        remap[i] = index[opt]
+    }
+    for i, code := range c.codes {
+        c.codes[i] = remap[code]
+    }
+    return c
+}
+
+// dataBuilder assembles SurveyData row by row.
+type dataBuilder struct {
+    schema   Schema
+    builders []*columnBuilder
+    n        int
+}
+
+func newDataBuilder(schema Schema) *dataBuilder {
+    b := &dataBuilder{schema: schema}
+    for _, entry := range schema {
+        b.builders = append(b.builders, newColumnBuilder(entry))
+    }
+    return b
+}
+
+// addRow appends a row given as one value per schema entry, in schema order.
+func (b *dataBuilder) addRow(vals []ResponseValue) {
+    for i, cb := range b.builders {
+        cb.append(vals[i])
+    }
+    b.n++
+}
+
+func (b *dataBuilder) add(resp Response) {
+    vals := make([]ResponseValue, len(b.schema))
+    for i, entry := range b.schema {
+        vals[i] = resp[entry.Key]
+    }
+    b.addRow(vals)
+}
+
+func (b *dataBuilder) build() *SurveyData {
+    sd := &SurveyData{
+        Schema:  b.schema,
+        IDKey:   DetectIDKey(b.schema),
+        columns: make(map[string]*Column, len(b.builders)),
+        n:       b.n,
+    }
+    for _, cb := range b.builders {
+        sd.columns[cb.col.Entry.Key] = cb.finish()
+    }
+    return sd
+}
+
+// SetNumericColumn stores values as a numeric question, adding it to the
+// schema if needed. NaN values are stored as missing.
+func (sd *SurveyData) SetNumericColumn(key, text string, values []float64) error {
+    if len(values) != sd.n {
+        return fmt.Errorf("got %d values for %d responses", len(values), sd.n)
+    }
+    entry, ok := sd.Schema.Get(key)
+    if !ok {
+        sd.Schema.add(key, text, NUM)
+        entry, _ = sd.Schema.Get(key)
+    } else if entry.QType != NUM {
+        return fmt.Errorf("question %q is not numeric", key)
+    }
+    b := newColumnBuilder(entry)
+    for _, v := range values {
+        if math.IsNaN(v) {
+            b.append(ResponseValue{Val: nil})
+        } else {
+            b.append(ResponseValue{Val: v})
+        }
+    }
+    sd.columns[key] = b.finish()
+    return nil
+}
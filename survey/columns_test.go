@@ -0,0 +1,99 @@
+package survey
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+func TestSurveyData_ColumnEncoding(t *testing.T) {
+    sd := NewSurveyData(
+        Schema{
+            {Key: "Q1", Text: "Favorite color", QType: SC},
+            {Key: "Q2", Text: "Languages", QType: MC},
+            {Key: "Q3", Text: "Comment", QType: TE},
+            {Key: "Q4", Text: "Years", QType: NUM},
+        },
+        []Response{
+            {"Q1": {Val: "red"}, "Q2": {Val: []string{"Python", "Go"}}, "Q3": {Val: "Nice!"}, "Q4": {Val: 3.0}},
+            {"Q1": {Val: "blue"}, "Q2": {Val: []string{}}, "Q3": {Val: nil}, "Q4": {Val: nil}},
+            {"Q1": {Val: nil}, "Q2": {Val: nil}},
+        },
+    )
+
+    if sd.Len() != 3 {
+        t.Fatalf("Len() = %d, want 3", sd.Len())
+    }
+
+    q1, _ := sd.Schema.Get("Q1")
+    if !reflect.DeepEqual(q1.UsedOptions, []string{"blue", "red"}) {
+        t.Errorf("Q1 UsedOptions = %v, want [blue red]", q1.UsedOptions)
+    }
+    col, ok := sd.Column("Q1")
+    if !ok {
+        t.Fatal("Column(Q1) not found")
+    }
+    if got := col.Code(0); got != 1 {
+        t.Errorf("Q1 code for row 0 = %d, want 1", got)
+    }
+    if got := col.Code(2); got != -1 {
+        t.Errorf("Q1 code for missing row = %d, want -1", got)
+    }
+
+    col, _ = sd.Column("Q2")
+    if got := col.Codes(0); !reflect.DeepEqual(got, []int32{1, 0}) {
+        t.Errorf("Q2 codes for row 0 = %v, want [1 0]", got)
+    }
+    if !col.Present(1) || len(col.Codes(1)) != 0 {
+        t.Errorf("Q2 row 1 should be present and empty")
+    }
+    if col.Present(2) {
+        t.Errorf("Q2 row 2 should be missing")
+    }
+
+    col, _ = sd.Column("Q4")
+    if f, ok := col.Float(0); !ok || f != 3 {
+        t.Errorf("Q4 Float(0) = %v, %v; want 3, true", f, ok)
+    }
+
+    want := Response{
+        "Q1": {Val: "red"},
+        "Q2": {Val: []string{"Python", "Go"}},
+        "Q3": {Val: "Nice!"},
+        "Q4": {Val: 3.0},
+    }
+    if got := sd.Response(0); !reflect.DeepEqual(got, want) {
+        t.Errorf("Response(0) = %#v, want %#v", got, want)
+    }
+    if sd.Value(2, "Q3").Present() {
+        t.Error("value for a key missing from the input should not be present")
+    }
+    if sd.Value(0, "QX").Present() {
+        t.Error("value for an unknown key should not be present")
+    }
+}
+
+func TestSurveyData_FreshAndCachedIdentical(t *testing.T) {
+    fresh, err := ReadSurveyData("so_test.xlsx")
+    if err != nil {
+        t.Fatalf("failed to read test data: %v", err)
+    }
+
+    var buf bytes.Buffer
+    if err := fresh.WriteJSON(&buf); err != nil {
+        t.Fatalf("WriteJSON failed: %v", err)
+    }
+    cached, err := LoadSurveyData(&buf)
+    if err != nil {
+        t.Fatalf("LoadSurveyData failed: %v", err)
+    }
+
+    if cached.Len() != fresh.Len() {
+        t.Fatalf("Len() = %d, want %d", cached.Len(), fresh.Len())
+    }
+    for row := 0; row < fresh.Len(); row++ {
+        if !reflect.DeepEqual(cached.Response(row), fresh.Response(row)) {
+            t.Errorf("cached response %d differs from fresh response", row)
+        }
+    }
+}
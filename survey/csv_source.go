@@ -0,0 +1,133 @@
+package survey
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// csvSource reads a pair of CSV files as published by Stack Overflow: the
+// responses (e.g. survey_results_public.csv) and the question schema
+// (survey_results_schema.csv).
+type csvSource struct {
+    filename   string
+    schemaFile string
+}
+
+// csvSchemaFilename derives the schema file belonging to a CSV data file.
+// A trailing "_public" is replaced, otherwise "_schema" is appended:
+// survey_results_public.csv -> survey_results_schema.csv, data.csv ->
+// data_schema.csv.
+func csvSchemaFilename(filename string) string {
+    base := strings.TrimSuffix(filename, filepath.Ext(filename))
+    base = strings.TrimSuffix(base, "_public")
+    return base + "_schema.csv"
+}
+
+func csvFiles(filename string) []string {
+    return []string{filename, csvSchemaFilename(filename)}
+}
+
+func openCSVSource(filename string) (Source, error) {
+    s := &csvSource{filename: filename, schemaFile: csvSchemaFilename(filename)}
+    for _, f := range s.Files() {
+        if _, err := os.Stat(f); err != nil {
+            return nil, fmt.Errorf("failed to open file: %w", err)
+        }
+    }
+    return s, nil
+}
+
+func (s *csvSource) Files() []string {
+    return csvFiles(s.filename)
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+    cr := csv.NewReader(r)
+    cr.FieldsPerRecord = -1
+    cr.LazyQuotes = true
+    cr.ReuseRecord = true
+    return cr
+}
+
+func (s *csvSource) Schema() (Schema, error) {
+    f, err := os.Open(s.schemaFile)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open schema file: %w", err)
+    }
+    defer f.Close()
+    cr := newCSVReader(f)
+    cr.ReuseRecord = false
+    rows, err := cr.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("failed to read schema file %s: %w", s.schemaFile, err)
+    }
+    return parseSchemaRows(rows), nil
+}
+
+func (s *csvSource) Rows() (RowReader, error) {
+    f, err := os.Open(s.filename)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open file: %w", err)
+    }
+    stat, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    return &csvRows{f: f, r: newCSVReader(f), size: stat.Size()}, nil
+}
+
+func (s *csvSource) Close() error {
+    return nil
+}
+
+type csvRows struct {
+    f      *os.File
+    r      *csv.Reader
+    size   int64
+    record []string
+    read   int
+    err    error
+}
+
+func (r *csvRows) Next() bool {
+    if r.err != nil {
+        return false
+    }
+    record, err := r.r.Read()
+    if err != nil {
+        if err != io.EOF {
+            r.err = err
+        }
+        return false
+    }
+    r.record = record
+    r.read++
+    return true
+}
+
+func (r *csvRows) Columns() ([]string, error) {
+    return r.record, nil
+}
+
+func (r *csvRows) Error() error {
+    return r.err
+}
+
+// Estimate extrapolates the number of data rows from the average size of
+// the rows read so far.
+func (r *csvRows) Estimate() int {
+    offset := r.r.InputOffset()
+    if offset <= 0 || r.read < 2 {
+        return 0
+    }
+    return int(int64(r.read)*r.size/offset) - 1
+}
+
+func (r *csvRows) Close() error {
+    return r.f.Close()
+}
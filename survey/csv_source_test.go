@@ -0,0 +1,121 @@
+package survey
+
+import (
+    "encoding/csv"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+
+    "github.com/xuri/excelize/v2"
+)
+
+func writeCSV(t *testing.T, filename string, rows [][]string) {
+    t.Helper()
+    f, err := os.Create(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    w := csv.NewWriter(f)
+    if err := w.WriteAll(rows); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestCSVSchemaFilename(t *testing.T) {
+    tests := map[string]string{
+        "survey_results_public.csv":   "survey_results_schema.csv",
+        "data.csv":                    "data_schema.csv",
+        filepath.Join("dir", "x.CSV"): filepath.Join("dir", "x_schema.csv"),
+    }
+    for in, want := range tests {
+        if got := csvSchemaFilename(in); got != want {
+            t.Errorf("csvSchemaFilename(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
+
+func TestReadSurveyData_CSVMatchesXLSX(t *testing.T) {
+    xlsxFile := filepath.Join(".", "so_test.xlsx")
+    f, err := excelize.OpenFile(xlsxFile)
+    if err != nil {
+        t.Fatalf("failed to open test data: %v", err)
+    }
+    schemaRows, err := f.GetRows("schema")
+    if err != nil {
+        t.Fatal(err)
+    }
+    dataRows, err := f.GetRows("raw data")
+    if err != nil {
+        t.Fatal(err)
+    }
+    f.Close()
+
+    dir := t.TempDir()
+    csvFile := filepath.Join(dir, "so_test_public.csv")
+    writeCSV(t, csvFile, dataRows)
+    writeCSV(t, filepath.Join(dir, "so_test_schema.csv"), schemaRows)
+
+    fromXLSX, err := ReadSurveyData(xlsxFile)
+    if err != nil {
+        t.Fatalf("ReadSurveyData(xlsx) failed: %v", err)
+    }
+    fromCSV, err := ReadSurveyData(csvFile)
+    if err != nil {
+        t.Fatalf("ReadSurveyData(csv) failed: %v", err)
+    }
+
+    if len(fromCSV.Sources) != 2 {
+        t.Errorf("CSV sources = %d, want 2 (data and schema)", len(fromCSV.Sources))
+    }
+    if !reflect.DeepEqual(fromCSV.Schema, fromXLSX.Schema) {
+        t.Error("CSV schema differs from xlsx schema")
+    }
+    if fromCSV.Len() != fromXLSX.Len() {
+        t.Fatalf("CSV Len() = %d, want %d", fromCSV.Len(), fromXLSX.Len())
+    }
+    for row := 0; row < fromXLSX.Len(); row++ {
+        if !reflect.DeepEqual(fromCSV.Response(row), fromXLSX.Response(row)) {
+            t.Errorf("CSV response %d differs from xlsx response", row)
+        }
+    }
+}
+
+func TestReadSurveyData_CSVPublishedSchema(t *testing.T) {
+    dir := t.TempDir()
+    csvFile := filepath.Join(dir, "results.csv")
+    writeCSV(t, filepath.Join(dir, "results_schema.csv"), [][]string{
+        {"qid", "qname", "question", "force_resp", "type", "selector"},
+        {"QID1", "Age", "What is your age?", "TRUE", "MC", "SAVR"},
+        {"QID2", "Language", "Which languages?", "FALSE", "MC", "MAVR"},
+        {"QID3", "Comment", "Anything else?", "FALSE", "TE", "SL"},
+    })
+    writeCSV(t, csvFile, [][]string{
+        {"ResponseId", "Age", "Language", "Comment"},
+        {"1", "18-24 years old", "Go;Rust", "Great survey"},
+        {"2", "NA", "Python", ""},
+    })
+
+    data, err := ReadSurveyDataWithOptions(csvFile, ReadOptions{Format: "csv"})
+    if err != nil {
+        t.Fatalf("ReadSurveyDataWithOptions failed: %v", err)
+    }
+    wantTypes := map[string]QuestionType{"Age": SC, "Language": MC, "Comment": TE}
+    for key, want := range wantTypes {
+        entry, ok := data.Schema.Get(key)
+        if !ok {
+            t.Errorf("schema entry %s missing", key)
+            continue
+        }
+        if entry.QType != want {
+            t.Errorf("%s QType = %s, want %s", key, entry.QType, want)
+        }
+    }
+    if got, _ := data.Value(0, "Language").AsStringSlice(); !reflect.DeepEqual(got, []string{"Go", "Rust"}) {
+        t.Errorf("Language of row 0 = %v, want [Go Rust]", got)
+    }
+    if data.Value(1, "Age").Present() || data.Value(1, "Comment").Present() {
+        t.Error("NA and empty cells should not be present")
+    }
+}
@@ -0,0 +1,244 @@
+package survey
+
+import (
+    "fmt"
+    "maps"
+    "slices"
+    "strconv"
+)
+
+// DerivedVar defines a derived question computed by an expression, see
+// CompileExpr.
+type DerivedVar struct {
+    Key  string       `json:"key"`
+    Text string       `json:"text,omitempty"`
+    Type QuestionType `json:"type,omitempty"` // inferred from the values if empty
+    Expr string       `json:"expr"`
+}
+
+// derivedValue converts the result of an expression to a ResponseValue of
+// the given question type.
+func derivedValue(v any, qtype QuestionType) (ResponseValue, error) {
+    if v == nil {
+        return ResponseValue{Val: nil}, nil
+    }
+    switch qtype {
+    case NUM:
+        if f, ok := v.(float64); ok {
+            return ResponseValue{Val: f}, nil
+        }
+    case SC, TE:
+        switch x := v.(type) {
+        case string:
+            return ResponseValue{Val: x}, nil
+        case bool:
+            if x {
+                return ResponseValue{Val: "Yes"}, nil
+            }
+            return ResponseValue{Val: "No"}, nil
+        case float64:
+            return ResponseValue{Val: strconv.FormatFloat(x, 'g', -1, 64)}, nil
+        }
+    case MC:
+        switch x := v.(type) {
+        case []string:
+            return ResponseValue{Val: x}, nil
+        case string:
+            return ResponseValue{Val: []string{x}}, nil
+        }
+    }
+    return ResponseValue{}, fmt.Errorf("cannot store %s as %s", exprTypeName(v), qtype)
+}
+
+// inferDerivedType picks the question type for expression results:
+// numbers become NUM, strings and booleans SC, lists MC.
+func inferDerivedType(vals []any) (QuestionType, error) {
+    var qtype QuestionType
+    for _, v := range vals {
+        var t QuestionType
+        switch v.(type) {
+        case nil:
+            continue
+        case float64:
+            t = NUM
+        case string, bool:
+            t = SC
+        case []string:
+            t = MC
+        }
+        if qtype != "" && t != qtype {
+            return "", fmt.Errorf("expression yields both %s and %s values", qtype, t)
+        }
+        qtype = t
+    }
+    if qtype == "" {
+        return "", fmt.Errorf("expression has no value for any response")
+    }
+    return qtype, nil
+}
+
+// Derive computes a derived question for all responses and adds it to the
+// schema, replacing an earlier definition of the same key. Derived
+// questions depending on a replaced one are recomputed. Definitions that
+// would make a question depend on itself are rejected. If any question
+// fails to compute, the data is left unchanged.
+func (sd *SurveyData) Derive(dv DerivedVar) (*SchemaEntry, error) {
+    if old, ok := sd.Schema.Get(dv.Key); ok && old.Derived == "" {
+        return nil, fmt.Errorf("question %q already exists", dv.Key)
+    }
+    // Compile against the schema without the question itself, so that it
+    // can't refer to its own earlier definition
+    others := slices.DeleteFunc(slices.Clone(sd.Schema), func(e *SchemaEntry) bool { return e.Key == dv.Key })
+    expr, err := CompileExpr(dv.Expr, others)
+    if err != nil {
+        return nil, fmt.Errorf("invalid expression for %q: %w", dv.Key, err)
+    }
+    for _, key := range expr.Keys {
+        if sd.dependsOn(key, dv.Key, make(map[string]bool)) {
+            return nil, fmt.Errorf("invalid expression for %q: %q depends on %q", dv.Key, key, dv.Key)
+        }
+    }
+
+    // Compute the question and its dependents on a copy of the schema and
+    // columns, which replaces them once everything succeeded
+    staged := *sd
+    staged.Schema = slices.Clone(sd.Schema)
+    staged.columns = maps.Clone(sd.columns)
+    entry, err := staged.derive(dv, expr)
+    if err != nil {
+        return nil, err
+    }
+    for _, dep := range sd.allDependents(dv.Key) {
+        def, ok := sd.Project.derived(dep.Key)
+        if !ok {
+            def = DerivedVar{Key: dep.Key, Text: dep.Text, Type: dep.QType, Expr: dep.Derived}
+        }
+        depExpr, err := CompileExpr(def.Expr, staged.Schema)
+        if err != nil {
+            return nil, fmt.Errorf("invalid expression for %q: %w", def.Key, err)
+        }
+        if _, err := staged.derive(def, depExpr); err != nil {
+            return nil, err
+        }
+    }
+    sd.Schema, sd.columns = staged.Schema, staged.columns
+    return entry, nil
+}
+
+// derive evaluates a compiled definition for all responses and stores the
+// question in place of an earlier definition, or at the end of the schema.
+func (sd *SurveyData) derive(dv DerivedVar, expr *Expr) (*SchemaEntry, error) {
+    vals := make([]any, sd.n)
+    var err error
+    for row := range vals {
+        if vals[row], err = expr.Eval(sd, row); err != nil {
+            return nil, fmt.Errorf("%q, response %d: %w", dv.Key, row+1, err)
+        }
+    }
+    qtype := dv.Type
+    if qtype == "" {
+        if qtype, err = inferDerivedType(vals); err != nil {
+            return nil, fmt.Errorf("%q: %w", dv.Key, err)
+        }
+    }
+    text := dv.Text
+    if text == "" {
+        text = dv.Expr
+    }
+    entry := &SchemaEntry{Key: dv.Key, Text: text, QType: qtype, UsedOptions: make([]string, 0), Derived: dv.Expr}
+    b := newColumnBuilder(entry)
+    for row, v := range vals {
+        rv, err := derivedValue(v, qtype)
+        if err != nil {
+            return nil, fmt.Errorf("%q, response %d: %w", dv.Key, row+1, err)
+        }
+        b.append(rv)
+    }
+
+    if pos := slices.IndexFunc(sd.Schema, func(e *SchemaEntry) bool { return e.Key == dv.Key }); pos >= 0 {
+        sd.Schema[pos] = entry
+    } else {
+        sd.Schema = append(sd.Schema, entry)
+    }
+    sd.columns[dv.Key] = b.finish()
+    return entry, nil
+}
+
+// dependsOn reports whether the question key is target or is derived from
+// it, directly or through other derived questions.
+func (sd *SurveyData) dependsOn(key, target string, visited map[string]bool) bool {
+    if key == target {
+        return true
+    }
+    if visited[key] {
+        return false
+    }
+    visited[key] = true
+    entry, ok := sd.Schema.Get(key)
+    if !ok || entry.Derived == "" {
+        return false
+    }
+    expr, err := CompileExpr(entry.Derived, sd.Schema)
+    if err != nil {
+        return false
+    }
+    for _, k := range expr.Keys {
+        if sd.dependsOn(k, target, visited) {
+            return true
+        }
+    }
+    return false
+}
+
+// allDependents returns the derived questions depending on key, directly
+// or through other derived questions. Each question comes after the ones
+// it depends on, so they can be recomputed in this order.
+func (sd *SurveyData) allDependents(key string) []*SchemaEntry {
+    var order []*SchemaEntry
+    visited := map[string]bool{key: true}
+    var visit func(key string)
+    visit = func(key string) {
+        for _, dep := range sd.dependents(key) {
+            if !visited[dep.Key] {
+                visited[dep.Key] = true
+                visit(dep.Key)
+                order = append(order, dep)
+            }
+        }
+    }
+    visit(key)
+    slices.Reverse(order)
+    return order
+}
+
+// dependents returns the derived questions whose expression refers to key.
+func (sd *SurveyData) dependents(key string) []*SchemaEntry {
+    var out []*SchemaEntry
+    for _, entry := range sd.Schema {
+        if entry.Derived == "" || entry.Key == key {
+            continue
+        }
+        if expr, err := CompileExpr(entry.Derived, sd.Schema); err == nil && slices.Contains(expr.Keys, key) {
+            out = append(out, entry)
+        }
+    }
+    return out
+}
+
+// RemoveDerived removes a derived question. It fails if other derived
+// questions depend on it.
+func (sd *SurveyData) RemoveDerived(key string) error {
+    entry, ok := sd.Schema.Get(key)
+    if !ok {
+        return fmt.Errorf("question %q not found", key)
+    }
+    if entry.Derived == "" {
+        return fmt.Errorf("question %q is not derived", key)
+    }
+    if deps := sd.dependents(key); len(deps) > 0 {
+        return fmt.Errorf("question %q is used by %q", key, deps[0].Key)
+    }
+    sd.Schema = slices.DeleteFunc(sd.Schema, func(e *SchemaEntry) bool { return e.Key == key })
+    delete(sd.columns, key)
+    return nil
+}
@@ -0,0 +1,186 @@
+package survey
+
+import (
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func derivedTestData() *SurveyData {
+    return NewSurveyData(
+        Schema{
+            {Key: "Lang", Text: "Languages", QType: MC},
+            {Key: "Years", Text: "Years of experience", QType: NUM},
+            {Key: "Cloud", Text: "Cloud provider", QType: SC},
+        },
+        []Response{
+            {"Lang": {Val: []string{"Go", "Rust"}}, "Years": {Val: 3.0}, "Cloud": {Val: "AWS"}},
+            {"Lang": {Val: []string{"Python"}}, "Years": {Val: 12.0}, "Cloud": {Val: "None"}},
+            {"Lang": {Val: nil}, "Years": {Val: nil}, "Cloud": {Val: "Azure"}},
+        },
+    )
+}
+
+func TestCompileExpr(t *testing.T) {
+    sd := derivedTestData()
+    tests := []struct {
+        expr string
+        want []any
+    }{
+        {`count(Lang)`, []any{2.0, 1.0, nil}},
+        {`Years * 2 + 1`, []any{7.0, 25.0, nil}},
+        {`has(Lang, "Go", "Python")`, []any{true, true, nil}},
+        {`Cloud != "None" and answered(Cloud)`, []any{true, false, true}},
+        {`answered(Lang) or Years > 10`, []any{true, true, nil}},
+        {`not answered(Years)`, []any{false, false, true}},
+        {`if(Years >= 10, "senior", "junior")`, []any{"junior", "senior", nil}},
+        {`bucket(Years, 5, 10)`, []any{"< 5", ">= 10", nil}},
+        {`num("Less than 1 year") + -(1)`, []any{-1.0, -1.0, -1.0}},
+    }
+    for _, tt := range tests {
+        expr, err := CompileExpr(tt.expr, sd.Schema)
+        if err != nil {
+            t.Errorf("CompileExpr(%q) failed: %v", tt.expr, err)
+            continue
+        }
+        for row, want := range tt.want {
+            got, err := expr.Eval(sd, row)
+            if err != nil || !reflect.DeepEqual(got, want) {
+                t.Errorf("%q row %d = %v, %v; want %v", tt.expr, row, got, err, want)
+            }
+        }
+    }
+
+    for _, bad := range []string{`Missing + 1`, `count(Lang`, `foo(Lang)`, `has(Lang)`, `"unclosed`, `1 +`} {
+        if _, err := CompileExpr(bad, sd.Schema); err == nil {
+            t.Errorf("CompileExpr(%q) should fail", bad)
+        }
+    }
+    expr, _ := CompileExpr(`Lang + 1`, sd.Schema)
+    if _, err := expr.Eval(sd, 0); err == nil {
+        t.Error("expected error adding a number to a list")
+    }
+}
+
+func TestSurveyData_Derive(t *testing.T) {
+    sd := derivedTestData()
+    entry, err := sd.Derive(DerivedVar{Key: "NLang", Expr: "count(Lang)"})
+    if err != nil {
+        t.Fatalf("Derive failed: %v", err)
+    }
+    if entry.QType != NUM || entry.Derived != "count(Lang)" {
+        t.Errorf("NLang entry = %+v", entry)
+    }
+    entry, err = sd.Derive(DerivedVar{Key: "Many", Expr: "NLang > 1"})
+    if err != nil {
+        t.Fatalf("Derive failed: %v", err)
+    }
+    if entry.QType != SC || !reflect.DeepEqual(entry.UsedOptions, []string{"No", "Yes"}) {
+        t.Errorf("Many entry = %+v", entry)
+    }
+    dist, err := sd.Distribution("Many", nil)
+    if err != nil || !reflect.DeepEqual(dist.Counts, []int{1, 1}) || dist.NA != 1 {
+        t.Errorf("Many distribution = %+v, %v", dist, err)
+    }
+
+    // Redefining NLang recomputes Many
+    if _, err := sd.Derive(DerivedVar{Key: "NLang", Expr: "count(Lang) - 1"}); err != nil {
+        t.Fatalf("Derive failed: %v", err)
+    }
+    if got := sd.Value(0, "Many").Val; got != "No" {
+        t.Errorf("Many after redefinition = %v, want No", got)
+    }
+    if len(sd.Schema) != 5 || sd.Schema[3].Key != "NLang" {
+        t.Errorf("redefinition should keep the schema position")
+    }
+
+    if _, err := sd.Derive(DerivedVar{Key: "Cloud", Expr: "1"}); err == nil {
+        t.Error("expected error overwriting a native question")
+    }
+    if _, err := sd.Derive(DerivedVar{Key: "Mixed", Expr: `if(Years > 5, 1, "x")`}); err == nil {
+        t.Error("expected error for mixed result types")
+    }
+    if err := sd.RemoveDerived("NLang"); err == nil {
+        t.Error("expected error removing a question other derived ones depend on")
+    }
+    if err := sd.RemoveDerived("Many"); err != nil {
+        t.Errorf("RemoveDerived failed: %v", err)
+    }
+    if _, ok := sd.Column("Many"); ok {
+        t.Error("Many column still present after removal")
+    }
+}
+
+func TestSurveyData_DeriveDependencies(t *testing.T) {
+    sd := derivedTestData()
+    for _, dv := range []DerivedVar{
+        {Key: "B", Expr: "Years + 1"},
+        {Key: "A", Expr: "B + 1"},
+        {Key: "C", Expr: "A + B"},
+    } {
+        if _, err := sd.Derive(dv); err != nil {
+            t.Fatalf("Derive(%s) failed: %v", dv.Key, err)
+        }
+    }
+
+    // Definitions depending on themselves are rejected, directly or not
+    for _, expr := range []string{"A + 1", "C * 2"} {
+        if _, err := sd.Derive(DerivedVar{Key: "B", Expr: expr}); err == nil {
+            t.Errorf("expected error for B = %s, which depends on B", expr)
+        }
+    }
+    if got := sd.Value(0, "B").Val; got != 4.0 {
+        t.Errorf("B after rejected definitions = %v, want 4", got)
+    }
+
+    // Dependents are recomputed in dependency order
+    if _, err := sd.Derive(DerivedVar{Key: "B", Expr: "Years * 2"}); err != nil {
+        t.Fatalf("Derive failed: %v", err)
+    }
+    if a, c := sd.Value(0, "A").Val, sd.Value(0, "C").Val; a != 7.0 || c != 13.0 {
+        t.Errorf("A, C after redefining B = %v, %v; want 7, 13", a, c)
+    }
+
+    // A dependent failing to compute leaves everything unchanged
+    if _, err := sd.Derive(DerivedVar{Key: "B", Expr: "Cloud"}); err == nil {
+        t.Fatal("expected error for dependents of a non-numeric B")
+    }
+    if b, a := sd.Value(0, "B").Val, sd.Value(0, "A").Val; b != 6.0 || a != 7.0 {
+        t.Errorf("B, A after failed redefinition = %v, %v; want 6, 7", b, a)
+    }
+    if entry, _ := sd.Schema.Get("B"); entry.QType != NUM {
+        t.Errorf("B type after failed redefinition = %s, want NUM", entry.QType)
+    }
+}
+
+func TestProject_SaveAndApply(t *testing.T) {
+    filename := filepath.Join(t.TempDir(), "project.json")
+    p, err := LoadProject(filename)
+    if err != nil {
+        t.Fatalf("LoadProject of a missing file failed: %v", err)
+    }
+    p.SetDerived(DerivedVar{Key: "Senior", Text: "Senior developer", Expr: "Years >= 10"})
+    p.SetDerived(DerivedVar{Key: "Broken", Expr: "Nope"})
+    if err := p.Save(); err != nil {
+        t.Fatalf("Save failed: %v", err)
+    }
+
+    p, err = LoadProject(filename)
+    if err != nil {
+        t.Fatalf("LoadProject failed: %v", err)
+    }
+    sd := derivedTestData()
+    if err := sd.ApplyProject(p); err == nil {
+        t.Error("expected error for the broken definition")
+    }
+    entry, ok := sd.Schema.Get("Senior")
+    if !ok || entry.Text != "Senior developer" {
+        t.Fatalf("Senior not derived: %+v", entry)
+    }
+    if got := sd.Value(1, "Senior").Val; got != "Yes" {
+        t.Errorf("Senior row 1 = %v, want Yes", got)
+    }
+    if sd.Project != p {
+        t.Error("ApplyProject should attach the project")
+    }
+}
@@ -0,0 +1,261 @@
+package survey
+
+import (
+    "fmt"
+    "slices"
+    "sort"
+    "strings"
+)
+
+// Severity grades a Diagnostic.
+type Severity string
+
+const (
+    SeverityError   Severity = "error"   // data is lost or unusable
+    SeverityWarning Severity = "warning" // data is probably misread
+    SeverityInfo    Severity = "info"    // worth a look, often harmless
+)
+
+// Kinds of diagnostics.
+const (
+    DiagDuplicateKey    = "duplicate-key"     // schema lists a key more than once
+    DiagUnknownType     = "unknown-type"      // schema type isn't SC, MC, TE, NUM or MATRIX
+    DiagUnknownColumn   = "unknown-column"    // data column isn't in the schema
+    DiagMissingColumn   = "missing-column"    // schema key isn't in the data
+    DiagDuplicateColumn = "duplicate-column"  // data header lists a key more than once
+    DiagShortRow        = "short-row"         // row has fewer cells than the header
+    DiagLongRow         = "long-row"          // row has more cells than the header
+    DiagUnparsedValue   = "unparsed-value"    // NUM cell isn't a number
+    DiagSCSemicolon     = "sc-semicolon"      // SC option contains ";", maybe MC
+    DiagSingletonOption = "singleton-option"  // MC option chosen only once
+    DiagUndeclared      = "undeclared-option" // answer isn't a declared option
+    DiagDuplicateID     = "duplicate-id"      // respondent ID used more than once
+    DiagMissingID       = "missing-id"        // response has no respondent ID
+    DiagUnknownMatrix   = "unknown-matrix"    // row names a matrix that doesn't exist
+    DiagMatrixRowType   = "matrix-row-type"   // matrix row isn't SC
+    DiagEmptyMatrix     = "empty-matrix"      // matrix has no rows
+)
+
+// maxDiagnosticRows is the number of example rows kept per diagnostic.
+const maxDiagnosticRows = 5
+
+// Diagnostic describes a problem found while loading or validating survey
+// data. Problems occurring in many rows are reported once, with the number
+// of occurrences and some example rows.
+type Diagnostic struct {
+    Severity Severity
+    Kind     string
+    Key      string `json:",omitempty"`
+    Message  string
+    Count    int   `json:",omitempty"`
+    Rows     []int `json:",omitempty"` // example data rows, starting at 1
+}
+
+func (d Diagnostic) String() string {
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "%s: %s", d.Severity, d.Message)
+    rows := make([]string, len(d.Rows))
+    for i, row := range d.Rows {
+        rows[i] = fmt.Sprint(row)
+    }
+    switch {
+    case d.Count > 1 && len(rows) > 0:
+        fmt.Fprintf(&sb, " (%d times, e.g. rows %s)", d.Count, strings.Join(rows, ", "))
+    case d.Count > 1:
+        fmt.Fprintf(&sb, " (%d times)", d.Count)
+    case len(rows) == 1:
+        fmt.Fprintf(&sb, " (row %s)", rows[0])
+    case len(rows) > 1:
+        fmt.Fprintf(&sb, " (rows %s)", strings.Join(rows, ", "))
+    }
+    return sb.String()
+}
+
+// diagnostics collects diagnostics, merging repeated ones of the same
+// kind and key.
+type diagnostics struct {
+    list  []Diagnostic
+    index map[string]int
+}
+
+// add records a diagnostic. row is the 1-based data row, or 0 if the
+// problem isn't tied to a row.
+func (ds *diagnostics) add(sev Severity, kind, key string, row int, format string, args ...any) {
+    if ds.index == nil {
+        ds.index = make(map[string]int)
+    }
+    id := kind + "\x00" + key
+    i, ok := ds.index[id]
+    if !ok {
+        i = len(ds.list)
+        ds.index[id] = i
+        ds.list = append(ds.list, Diagnostic{
+            Severity: sev,
+            Kind:     kind,
+            Key:      key,
+            Message:  fmt.Sprintf(format, args...),
+        })
+    }
+    d := &ds.list[i]
+    d.Count++
+    if row > 0 && len(d.Rows) < maxDiagnosticRows {
+        d.Rows = append(d.Rows, row)
+    }
+}
+
+var severityOrder = map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+
+// sortDiagnostics orders diagnostics by severity, keeping the order of
+// discovery otherwise.
+func sortDiagnostics(list []Diagnostic) {
+    sort.SliceStable(list, func(i, j int) bool {
+        return severityOrder[list[i].Severity] < severityOrder[list[j].Severity]
+    })
+}
+
+// validSchema removes duplicate keys from a schema read from a source and
+// reports them and unknown question types.
+func validSchema(schema Schema, ds *diagnostics) Schema {
+    out := make(Schema, 0, len(schema))
+    for _, entry := range schema {
+        if _, exists := out.Get(entry.Key); exists {
+            ds.add(SeverityError, DiagDuplicateKey, entry.Key, 0,
+                "schema lists %q more than once, only the first entry is used", entry.Key)
+            continue
+        }
+        switch entry.QType {
+        case SC, MC, TE, NUM, MATRIX:
+        default:
+            ds.add(SeverityError, DiagUnknownType, entry.Key, 0,
+                "question %q has unknown type %q, its answers are not read", entry.Key, entry.QType)
+        }
+        out = append(out, entry)
+    }
+    return out
+}
+
+// quoteList formats the first few strings for a diagnostic message.
+func quoteList(ss []string) string {
+    quoted := make([]string, 0, maxDiagnosticRows+1)
+    for i, s := range ss {
+        if i == maxDiagnosticRows {
+            quoted = append(quoted, fmt.Sprintf("and %d more", len(ss)-i))
+            break
+        }
+        quoted = append(quoted, fmt.Sprintf("%q", s))
+    }
+    return strings.Join(quoted, ", ")
+}
+
+func exampleRows(rows []int) []int {
+    sort.Ints(rows)
+    rows = slices.Compact(rows)
+    return rows[:min(len(rows), maxDiagnosticRows)]
+}
+
+// Validate returns the diagnostics recorded while the data was read from
+// its source, followed by checks of the loaded answers: answers that
+// aren't declared options, SC options containing ";", MC options chosen
+// by a single respondent, and missing or duplicate respondent IDs.
+func (sd *SurveyData) Validate() []Diagnostic {
+    list := slices.Clone(sd.Diagnostics)
+    list = append(list, sd.validateIDs()...)
+    for _, entry := range sd.Schema {
+        col, ok := sd.Column(entry.Key)
+        if !ok || entry.QType != SC && entry.QType != MC {
+            continue
+        }
+        counts := make([]int, len(entry.UsedOptions))
+        firstRow := make([]int, len(entry.UsedOptions))
+        for row := 0; row < sd.n; row++ {
+            var codes []int32
+            if entry.QType == SC {
+                if code := col.Code(row); code >= 0 {
+                    codes = []int32{int32(code)}
+                }
+            } else {
+                codes = col.Codes(row)
+            }
+            for _, code := range codes {
+                if counts[code] == 0 {
+                    firstRow[code] = row + 1
+                }
+                counts[code]++
+            }
+        }
+        var undeclared, semicolon, singletons []string
+        var undeclaredCount, semicolonCount int
+        var undeclaredRows, semicolonRows, singletonRows []int
+        for code, opt := range entry.UsedOptions {
+            if !entry.Declared(opt) && counts[code] > 0 {
+                undeclared = append(undeclared, opt)
+                undeclaredCount += counts[code]
+                undeclaredRows = append(undeclaredRows, firstRow[code])
+            }
+            if entry.QType == SC && strings.Contains(opt, ";") {
+                semicolon = append(semicolon, opt)
+                semicolonCount += counts[code]
+                semicolonRows = append(semicolonRows, firstRow[code])
+            }
+            if entry.QType == MC && counts[code] == 1 {
+                singletons = append(singletons, opt)
+                singletonRows = append(singletonRows, firstRow[code])
+            }
+        }
+        if len(undeclared) > 0 {
+            list = append(list, Diagnostic{
+                Severity: SeverityWarning,
+                Kind:     DiagUndeclared,
+                Key:      entry.Key,
+                Message:  fmt.Sprintf("question %q has answers that are not declared options: %s", entry.Key, quoteList(undeclared)),
+                Count:    undeclaredCount,
+                Rows:     exampleRows(undeclaredRows),
+            })
+        }
+        if len(semicolon) > 0 {
+            list = append(list, Diagnostic{
+                Severity: SeverityWarning,
+                Kind:     DiagSCSemicolon,
+                Key:      entry.Key,
+                Message: fmt.Sprintf("single-choice question %q has answers containing \";\", it may be multiple choice: %s",
+                    entry.Key, quoteList(semicolon)),
+                Count: semicolonCount,
+                Rows:  exampleRows(semicolonRows),
+            })
+        }
+        if len(singletons) > 0 {
+            list = append(list, Diagnostic{
+                Severity: SeverityInfo,
+                Kind:     DiagSingletonOption,
+                Key:      entry.Key,
+                Message:  fmt.Sprintf("multiple-choice question %q has options chosen only once: %s", entry.Key, quoteList(singletons)),
+                Rows:     exampleRows(singletonRows),
+            })
+        }
+    }
+    sortDiagnostics(list)
+    return list
+}
+
+// validateIDs reports responses without a respondent ID and IDs shared by
+// several responses.
+func (sd *SurveyData) validateIDs() []Diagnostic {
+    if sd.IDKey == "" {
+        return nil
+    }
+    ds := &diagnostics{}
+    seen := make(map[string]bool, sd.n)
+    for row := 0; row < sd.n; row++ {
+        id := sd.ID(row)
+        switch {
+        case id == "":
+            ds.add(SeverityWarning, DiagMissingID, sd.IDKey, row+1,
+                "responses have no %s, they can't be selected by ID", sd.IDKey)
+        case seen[id]:
+            ds.add(SeverityWarning, DiagDuplicateID, sd.IDKey, row+1,
+                "%s values are used by more than one response", sd.IDKey)
+        }
+        seen[id] = true
+    }
+    return ds.list
+}
@@ -0,0 +1,89 @@
+package survey
+
+import (
+    "bytes"
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+func findDiagnostic(diags []Diagnostic, kind, key string) (Diagnostic, bool) {
+    for _, d := range diags {
+        if d.Kind == kind && d.Key == key {
+            return d, true
+        }
+    }
+    return Diagnostic{}, false
+}
+
+func TestReadSurveyData_Diagnostics(t *testing.T) {
+    dir := t.TempDir()
+    dataFile := filepath.Join(dir, "data.csv")
+    writeCSV(t, filepath.Join(dir, "data_schema.csv"), [][]string{
+        {"column", "question_text", "type"},
+        {"OS", "Operating system", "SC"},
+        {"Lang", "Languages", "MC"},
+        {"Age", "Age", "NUM"},
+        {"Rating", "Rating", "STARS"},
+        {"OS", "Operating system again", "SC"},
+        {"Gone", "Not in the data", "TE"},
+    })
+    writeCSV(t, dataFile, [][]string{
+        {"OS", "Lang", "Age", "Rating", "Extra"},
+        {"Linux;Windows", "Go;Rust", "thirty", "5", "x"},
+        {"Linux", "Go", "30"},
+        {"Linux", "Go;Perl", "40", "4", "y", "overflow"},
+    })
+
+    sd, err := ReadSurveyData(dataFile)
+    if err != nil {
+        t.Fatalf("ReadSurveyData failed: %v", err)
+    }
+    diags := sd.Validate()
+    tests := []struct {
+        kind, key string
+        sev       Severity
+        rows      []int
+    }{
+        {DiagDuplicateKey, "OS", SeverityError, nil},
+        {DiagUnknownType, "Rating", SeverityError, nil},
+        {DiagUnknownColumn, "Extra", SeverityWarning, nil},
+        {DiagMissingColumn, "Gone", SeverityWarning, nil},
+        {DiagShortRow, "", SeverityInfo, []int{2}},
+        {DiagLongRow, "", SeverityWarning, []int{3}},
+        {DiagUnparsedValue, "Age", SeverityWarning, []int{1}},
+        {DiagSCSemicolon, "OS", SeverityWarning, []int{1}},
+        {DiagSingletonOption, "Lang", SeverityInfo, []int{1, 3}},
+    }
+    for _, tt := range tests {
+        d, ok := findDiagnostic(diags, tt.kind, tt.key)
+        if !ok {
+            t.Errorf("missing %s diagnostic for %q", tt.kind, tt.key)
+            continue
+        }
+        if d.Severity != tt.sev {
+            t.Errorf("%s %q severity = %s, want %s", tt.kind, tt.key, d.Severity, tt.sev)
+        }
+        if tt.rows != nil && !slices.Equal(d.Rows, tt.rows) {
+            t.Errorf("%s %q rows = %v, want %v", tt.kind, tt.key, d.Rows, tt.rows)
+        }
+    }
+    for i := 1; i < len(diags); i++ {
+        if severityOrder[diags[i-1].Severity] > severityOrder[diags[i].Severity] {
+            t.Errorf("diagnostics not ordered by severity: %v before %v", diags[i-1], diags[i])
+        }
+    }
+
+    // Load diagnostics survive the cache
+    var buf bytes.Buffer
+    if err := sd.WriteBinary(&buf); err != nil {
+        t.Fatal(err)
+    }
+    loaded, err := LoadSurveyDataBinary(buf.Bytes())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(loaded.Diagnostics) != len(sd.Diagnostics) {
+        t.Errorf("cached diagnostics = %d, want %d", len(loaded.Diagnostics), len(sd.Diagnostics))
+    }
+}
@@ -0,0 +1,120 @@
+package survey
+
+import "fmt"
+
+// Distribution counts how often each option of an SC or MC question was
+// chosen. Counts is indexed like Entry.UsedOptions. The Weighted fields
+// hold the same figures summed over the respondent weights; they equal the
+// plain counts for unweighted data.
+type Distribution struct {
+    Entry       *SchemaEntry
+    Counts      []int
+    NA          int // responses without an answer
+    Respondents int // responses with at least one option chosen
+
+    Weighted            []float64
+    WeightedNA          float64
+    WeightedRespondents float64
+}
+
+// Total returns the sum of all option counts and n/a, i.e. the number of
+// responses for SC and the number of mentions plus n/a for MC questions.
+func (d *Distribution) Total() int {
+    total := d.NA
+    for _, cnt := range d.Counts {
+        total += cnt
+    }
+    return total
+}
+
+// WeightedTotal is the weighted counterpart of Total.
+func (d *Distribution) WeightedTotal() float64 {
+    total := d.WeightedNA
+    for _, w := range d.Weighted {
+        total += w
+    }
+    return total
+}
+
+// Distribution counts the options of the given question over the given
+// rows, or over all responses if rows is nil.
+func (sd *SurveyData) Distribution(key string, rows []int) (*Distribution, error) {
+    entry, ok := sd.Schema.Get(key)
+    if !ok {
+        return nil, fmt.Errorf("question %q not found", key)
+    }
+    if entry.QType != SC && entry.QType != MC {
+        return nil, fmt.Errorf("question %q is not single or multi choice", key)
+    }
+    col, ok := sd.Column(key)
+    if !ok {
+        return nil, fmt.Errorf("question %q has no data", key)
+    }
+    if rows == nil {
+        rows = sd.Rows()
+    }
+
+    d := &Distribution{
+        Entry:    entry,
+        Counts:   make([]int, len(entry.UsedOptions)),
+        Weighted: make([]float64, len(entry.UsedOptions)),
+    }
+    for _, row := range rows {
+        w := sd.Weight(row)
+        if entry.QType == SC {
+            code := col.Code(row)
+            if code < 0 {
+                d.NA++
+                d.WeightedNA += w
+                continue
+            }
+            d.Counts[code]++
+            d.Weighted[code] += w
+        } else {
+            codes := col.Codes(row)
+            // If no valid options found, count as n/a
+            if len(codes) == 0 {
+                d.NA++
+                d.WeightedNA += w
+                continue
+            }
+            for _, code := range codes {
+                d.Counts[code]++
+                d.Weighted[code] += w
+            }
+        }
+        d.Respondents++
+        d.WeightedRespondents += w
+    }
+    return d, nil
+}
+
+// Median returns the code of the median option of an ordinal SC question:
+// the first declared option at which the weighted share of the answers
+// reaches one half. Answers that aren't declared options are left out.
+// ok is false for other questions or if there are no such answers.
+func (d *Distribution) Median() (code int, ok bool) {
+    entry := d.Entry
+    if entry.QType != SC || !entry.Ordinal || len(entry.Options) == 0 {
+        return -1, false
+    }
+    total := 0.0
+    for code, opt := range entry.UsedOptions {
+        if entry.Declared(opt) {
+            total += d.Weighted[code]
+        }
+    }
+    if total == 0 {
+        return -1, false
+    }
+    sum := 0.0
+    for code, opt := range entry.UsedOptions {
+        if !entry.Declared(opt) {
+            continue
+        }
+        if sum += d.Weighted[code]; sum >= total/2 {
+            return code, true
+        }
+    }
+    return -1, false
+}
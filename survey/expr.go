@@ -0,0 +1,675 @@
+package survey
+
+import (
+    "fmt"
+    "slices"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// Expressions compute derived questions from the answers of a response.
+// They support
+//
+//    literals     12, 3.5, "text", true, false
+//    questions    YearsCode, LanguageHaveWorkedWith, [OpSysPersonal use]
+//    arithmetic   + - * /
+//    comparison   == != < <= > >= (= is accepted for ==)
+//    logic        and, or, not (also &&, ||, !)
+//    functions    if(cond, a, b), answered(q), count(q), has(q, opt...),
+//                 num(q), bucket(x, bound...)
+//
+// Values are numbers (NUM questions), strings (SC and TE questions), lists
+// of strings (MC questions), booleans and nil for missing answers. Nil
+// propagates through operators and functions, except answered and the
+// short-circuiting and/or.
+
+// exprNode is a node of a parsed expression.
+type exprNode interface {
+    eval(sd *SurveyData, row int) (any, error)
+}
+
+type exprLiteral struct{ val any }
+
+type exprQuestion struct{ entry *SchemaEntry }
+
+type exprUnary struct {
+    op string
+    x  exprNode
+}
+
+type exprBinary struct {
+    op   string
+    x, y exprNode
+}
+
+type exprCall struct {
+    name string
+    args []exprNode
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+    Source string
+    root   exprNode
+    // Keys are the questions the expression refers to
+    Keys []string
+}
+
+// Eval evaluates the expression for one response.
+func (e *Expr) Eval(sd *SurveyData, row int) (any, error) {
+    return e.root.eval(sd, row)
+}
+
+// exprFuncs lists the functions with their minimum and maximum number of
+// arguments (-1 for any).
+var exprFuncs = map[string][2]int{
+    "if":       {3, 3},
+    "answered": {1, 1},
+    "count":    {1, 1},
+    "has":      {2, -1},
+    "num":      {1, 1},
+    "bucket":   {2, -1},
+}
+
+// CompileExpr parses an expression and resolves its question keys against
+// the schema.
+func CompileExpr(src string, schema Schema) (*Expr, error) {
+    tokens, err := lexExpr(src)
+    if err != nil {
+        return nil, err
+    }
+    p := &exprParser{tokens: tokens, schema: schema}
+    root, err := p.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    if p.pos < len(p.tokens) {
+        return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+    }
+    return &Expr{Source: src, root: root, Keys: p.keys}, nil
+}
+
+type exprTokenKind int
+
+const (
+    tokNumber exprTokenKind = iota
+    tokString
+    tokIdent
+    tokKey // question key in brackets, e.g. [OpSysProfessional use]
+    tokOp
+)
+
+type exprToken struct {
+    kind exprTokenKind
+    text string
+    pos  int // rune offset in the source
+}
+
+// SyntaxError is an error in the text of an expression or query at the
+// given 1-based line and column.
+type SyntaxError struct {
+    Line, Column int
+    Msg          string
+}
+
+func (e *SyntaxError) Error() string {
+    if e.Line > 1 {
+        return fmt.Sprintf("%s at line %d, column %d", e.Msg, e.Line, e.Column)
+    }
+    return fmt.Sprintf("%s at column %d", e.Msg, e.Column)
+}
+
+// syntaxError returns a SyntaxError at the rune offset pos of src.
+func syntaxError(src string, pos int, format string, args ...any) *SyntaxError {
+    line, col := 1, 1
+    for i, r := range []rune(src) {
+        if i == pos {
+            break
+        }
+        if r == '\n' {
+            line, col = line+1, 1
+        } else {
+            col++
+        }
+    }
+    return &SyntaxError{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+var exprOps = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "=", "!", "+", "-", "*", "/", "(", ")", ","}
+
+func lexExpr(src string) ([]exprToken, error) {
+    var tokens []exprToken
+    rs := []rune(src)
+    for i := 0; i < len(rs); {
+        r := rs[i]
+        switch {
+        case unicode.IsSpace(r):
+            i++
+        case unicode.IsDigit(r) || r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]):
+            j := i
+            for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+                j++
+            }
+            tokens = append(tokens, exprToken{tokNumber, string(rs[i:j]), i})
+            i = j
+        case r == '"' || r == '\'':
+            var sb strings.Builder
+            j := i + 1
+            for ; j < len(rs) && rs[j] != r; j++ {
+                if rs[j] == '\\' && j+1 < len(rs) {
+                    j++
+                }
+                sb.WriteRune(rs[j])
+            }
+            if j >= len(rs) {
+                return nil, syntaxError(src, i, "unclosed string")
+            }
+            tokens = append(tokens, exprToken{tokString, sb.String(), i})
+            i = j + 1
+        case r == '[':
+            // A doubled ]] stands for a ] in the key
+            var sb strings.Builder
+            j := i + 1
+            for ; j < len(rs); j++ {
+                if rs[j] == ']' {
+                    if j+1 >= len(rs) || rs[j+1] != ']' {
+                        break
+                    }
+                    j++
+                }
+                sb.WriteRune(rs[j])
+            }
+            if j >= len(rs) {
+                return nil, syntaxError(src, i, "unclosed [")
+            }
+            tokens = append(tokens, exprToken{tokKey, sb.String(), i})
+            i = j + 1
+        case unicode.IsLetter(r) || r == '_':
+            j := i
+            for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+                j++
+            }
+            tokens = append(tokens, exprToken{tokIdent, string(rs[i:j]), i})
+            i = j
+        default:
+            op := ""
+            for _, o := range exprOps {
+                if strings.HasPrefix(string(rs[i:]), o) {
+                    op = o
+                    break
+                }
+            }
+            if op == "" {
+                return nil, syntaxError(src, i, "unexpected character %q", r)
+            }
+            tokens = append(tokens, exprToken{tokOp, op, i})
+            i += len([]rune(op))
+        }
+    }
+    return tokens, nil
+}
+
+type exprParser struct {
+    tokens []exprToken
+    pos    int
+    schema Schema
+    keys   []string
+}
+
+func (p *exprParser) peek() (exprToken, bool) {
+    if p.pos >= len(p.tokens) {
+        return exprToken{}, false
+    }
+    return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is one of the given operators or
+// keywords and returns it.
+func (p *exprParser) accept(ops ...string) (string, bool) {
+    tok, ok := p.peek()
+    if !ok || tok.kind != tokOp && tok.kind != tokIdent || !slices.Contains(ops, tok.text) {
+        return "", false
+    }
+    p.pos++
+    return tok.text, true
+}
+
+func (p *exprParser) expect(op string) error {
+    if _, ok := p.accept(op); !ok {
+        if tok, ok := p.peek(); ok {
+            return fmt.Errorf("expected %q, got %q", op, tok.text)
+        }
+        return fmt.Errorf("expected %q at end of expression", op)
+    }
+    return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+    x, err := p.parseAnd()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        if _, ok := p.accept("or", "||"); !ok {
+            return x, nil
+        }
+        y, err := p.parseAnd()
+        if err != nil {
+            return nil, err
+        }
+        x = &exprBinary{"or", x, y}
+    }
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+    x, err := p.parseNot()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        if _, ok := p.accept("and", "&&"); !ok {
+            return x, nil
+        }
+        y, err := p.parseNot()
+        if err != nil {
+            return nil, err
+        }
+        x = &exprBinary{"and", x, y}
+    }
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+    if _, ok := p.accept("not", "!"); ok {
+        x, err := p.parseNot()
+        if err != nil {
+            return nil, err
+        }
+        return &exprUnary{"not", x}, nil
+    }
+    return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+    x, err := p.parseSum()
+    if err != nil {
+        return nil, err
+    }
+    op, ok := p.accept("==", "=", "!=", "<", "<=", ">", ">=")
+    if !ok {
+        return x, nil
+    }
+    if op == "=" {
+        op = "=="
+    }
+    y, err := p.parseSum()
+    if err != nil {
+        return nil, err
+    }
+    return &exprBinary{op, x, y}, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+    x, err := p.parseProduct()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        op, ok := p.accept("+", "-")
+        if !ok {
+            return x, nil
+        }
+        y, err := p.parseProduct()
+        if err != nil {
+            return nil, err
+        }
+        x = &exprBinary{op, x, y}
+    }
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+    x, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        op, ok := p.accept("*", "/")
+        if !ok {
+            return x, nil
+        }
+        y, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        x = &exprBinary{op, x, y}
+    }
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+    if _, ok := p.accept("-"); ok {
+        x, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        return &exprUnary{"-", x}, nil
+    }
+    return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+    tok, ok := p.peek()
+    if !ok {
+        return nil, fmt.Errorf("unexpected end of expression")
+    }
+    p.pos++
+    switch tok.kind {
+    case tokNumber:
+        f, err := strconv.ParseFloat(tok.text, 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid number %q", tok.text)
+        }
+        return &exprLiteral{f}, nil
+    case tokString:
+        return &exprLiteral{tok.text}, nil
+    case tokIdent, tokKey:
+        if tok.kind == tokIdent {
+            switch tok.text {
+            case "true":
+                return &exprLiteral{true}, nil
+            case "false":
+                return &exprLiteral{false}, nil
+            }
+            if _, ok := p.accept("("); ok {
+                return p.parseCall(tok.text)
+            }
+        }
+        entry, ok := p.schema.Get(tok.text)
+        if !ok {
+            return nil, fmt.Errorf("unknown question %q", tok.text)
+        }
+        if !slices.Contains(p.keys, entry.Key) {
+            p.keys = append(p.keys, entry.Key)
+        }
+        return &exprQuestion{entry}, nil
+    default:
+        if tok.text == "(" {
+            x, err := p.parseOr()
+            if err != nil {
+                return nil, err
+            }
+            return x, p.expect(")")
+        }
+        return nil, fmt.Errorf("unexpected %q", tok.text)
+    }
+}
+
+func (p *exprParser) parseCall(name string) (exprNode, error) {
+    arity, ok := exprFuncs[name]
+    if !ok {
+        return nil, fmt.Errorf("unknown function %q", name)
+    }
+    call := &exprCall{name: name}
+    if _, ok := p.accept(")"); !ok {
+        for {
+            arg, err := p.parseOr()
+            if err != nil {
+                return nil, err
+            }
+            call.args = append(call.args, arg)
+            if _, ok := p.accept(","); !ok {
+                break
+            }
+        }
+        if err := p.expect(")"); err != nil {
+            return nil, err
+        }
+    }
+    if len(call.args) < arity[0] || arity[1] >= 0 && len(call.args) > arity[1] {
+        return nil, fmt.Errorf("wrong number of arguments for %s", name)
+    }
+    return call, nil
+}
+
+func (n *exprLiteral) eval(sd *SurveyData, row int) (any, error) {
+    return n.val, nil
+}
+
+func (n *exprQuestion) eval(sd *SurveyData, row int) (any, error) {
+    return sd.Value(row, n.entry.Key).Val, nil
+}
+
+func exprTypeName(v any) string {
+    switch v.(type) {
+    case float64:
+        return "number"
+    case string:
+        return "string"
+    case bool:
+        return "boolean"
+    case []string:
+        return "list"
+    }
+    return fmt.Sprintf("%T", v)
+}
+
+func (n *exprUnary) eval(sd *SurveyData, row int) (any, error) {
+    x, err := n.x.eval(sd, row)
+    if err != nil || x == nil {
+        return nil, err
+    }
+    switch n.op {
+    case "not":
+        b, ok := x.(bool)
+        if !ok {
+            return nil, fmt.Errorf("not needs a boolean, got %s", exprTypeName(x))
+        }
+        return !b, nil
+    default:
+        f, ok := x.(float64)
+        if !ok {
+            return nil, fmt.Errorf("- needs a number, got %s", exprTypeName(x))
+        }
+        return -f, nil
+    }
+}
+
+func (n *exprBinary) eval(sd *SurveyData, row int) (any, error) {
+    x, err := n.x.eval(sd, row)
+    if err != nil {
+        return nil, err
+    }
+    // and/or short-circuit so that a known result wins over a missing value
+    if n.op == "and" || n.op == "or" {
+        if x != nil {
+            b, ok := x.(bool)
+            if !ok {
+                return nil, fmt.Errorf("%s needs booleans, got %s", n.op, exprTypeName(x))
+            }
+            if b == (n.op == "or") {
+                return b, nil
+            }
+        }
+        y, err := n.y.eval(sd, row)
+        if err != nil || y == nil {
+            return nil, err
+        }
+        yb, ok := y.(bool)
+        if !ok {
+            return nil, fmt.Errorf("%s needs booleans, got %s", n.op, exprTypeName(y))
+        }
+        if x == nil && yb != (n.op == "or") {
+            return nil, nil
+        }
+        return yb, nil
+    }
+    y, err := n.y.eval(sd, row)
+    if err != nil || x == nil || y == nil {
+        return nil, err
+    }
+
+    if n.op == "==" || n.op == "!=" {
+        var eq bool
+        switch xv := x.(type) {
+        case []string:
+            yv, ok := y.([]string)
+            if !ok {
+                return nil, fmt.Errorf("cannot compare list with %s, use has()", exprTypeName(y))
+            }
+            eq = slices.Equal(xv, yv)
+        default:
+            if exprTypeName(x) != exprTypeName(y) {
+                return nil, fmt.Errorf("cannot compare %s with %s", exprTypeName(x), exprTypeName(y))
+            }
+            eq = x == y
+        }
+        return eq == (n.op == "=="), nil
+    }
+
+    if xs, ok := x.(string); ok {
+        ys, ok := y.(string)
+        if !ok || n.op == "-" || n.op == "*" || n.op == "/" {
+            return nil, fmt.Errorf("invalid operation %s %s %s", exprTypeName(x), n.op, exprTypeName(y))
+        }
+        switch n.op {
+        case "+":
+            return xs + ys, nil
+        case "<":
+            return xs < ys, nil
+        case "<=":
+            return xs <= ys, nil
+        case ">":
+            return xs > ys, nil
+        default:
+            return xs >= ys, nil
+        }
+    }
+    xf, ok1 := x.(float64)
+    yf, ok2 := y.(float64)
+    if !ok1 || !ok2 {
+        return nil, fmt.Errorf("invalid operation %s %s %s", exprTypeName(x), n.op, exprTypeName(y))
+    }
+    switch n.op {
+    case "+":
+        return xf + yf, nil
+    case "-":
+        return xf - yf, nil
+    case "*":
+        return xf * yf, nil
+    case "/":
+        if yf == 0 {
+            return nil, nil
+        }
+        return xf / yf, nil
+    case "<":
+        return xf < yf, nil
+    case "<=":
+        return xf <= yf, nil
+    case ">":
+        return xf > yf, nil
+    default:
+        return xf >= yf, nil
+    }
+}
+
+func (n *exprCall) eval(sd *SurveyData, row int) (any, error) {
+    if n.name == "if" {
+        cond, err := n.args[0].eval(sd, row)
+        if err != nil || cond == nil {
+            return nil, err
+        }
+        b, ok := cond.(bool)
+        if !ok {
+            return nil, fmt.Errorf("if needs a boolean condition, got %s", exprTypeName(cond))
+        }
+        if b {
+            return n.args[1].eval(sd, row)
+        }
+        return n.args[2].eval(sd, row)
+    }
+
+    args := make([]any, len(n.args))
+    for i, arg := range n.args {
+        v, err := arg.eval(sd, row)
+        if err != nil {
+            return nil, err
+        }
+        args[i] = v
+    }
+    if n.name == "answered" {
+        if ss, ok := args[0].([]string); ok {
+            return len(ss) > 0, nil
+        }
+        return args[0] != nil, nil
+    }
+    if args[0] == nil {
+        return nil, nil
+    }
+    switch n.name {
+    case "count":
+        if ss, ok := args[0].([]string); ok {
+            return float64(len(ss)), nil
+        }
+        return 1.0, nil
+    case "has":
+        for _, arg := range args[1:] {
+            opt, ok := arg.(string)
+            if !ok {
+                return nil, fmt.Errorf("has needs string options, got %s", exprTypeName(arg))
+            }
+            switch v := args[0].(type) {
+            case []string:
+                if slices.Contains(v, opt) {
+                    return true, nil
+                }
+            case string:
+                if v == opt {
+                    return true, nil
+                }
+            default:
+                return nil, fmt.Errorf("has needs a choice question, got %s", exprTypeName(v))
+            }
+        }
+        return false, nil
+    case "num":
+        switch v := args[0].(type) {
+        case float64:
+            return v, nil
+        case string:
+            if f, ok := parseNumericLabel(strings.ToLower(strings.TrimSpace(v))); ok {
+                return f, nil
+            }
+            return nil, nil
+        }
+        return nil, fmt.Errorf("num needs a number or string, got %s", exprTypeName(args[0]))
+    case "bucket":
+        return bucketLabel(args)
+    }
+    return nil, fmt.Errorf("unknown function %q", n.name)
+}
+
+// bucketLabel implements bucket(x, b1, b2, ...): the label of the interval
+// x falls in, "< b1", "b1 to < b2", ..., ">= bn".
+func bucketLabel(args []any) (any, error) {
+    x, ok := args[0].(float64)
+    if !ok {
+        return nil, fmt.Errorf("bucket needs a number, got %s", exprTypeName(args[0]))
+    }
+    bounds := make([]float64, len(args)-1)
+    for i, arg := range args[1:] {
+        b, ok := arg.(float64)
+        if !ok || i > 0 && b <= bounds[i-1] {
+            return nil, fmt.Errorf("bucket needs increasing numeric bounds")
+        }
+        bounds[i] = b
+    }
+    format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+    if x < bounds[0] {
+        return "< " + format(bounds[0]), nil
+    }
+    for i := 1; i < len(bounds); i++ {
+        if x < bounds[i] {
+            return format(bounds[i-1]) + " to < " + format(bounds[i]), nil
+        }
+    }
+    return ">= " + format(bounds[len(bounds)-1]), nil
+}
@@ -0,0 +1,101 @@
+package survey
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// IDKeys are the question keys recognized as respondent IDs, in order of
+// preference. ResponseId is the one used by the Stack Overflow exports.
+var IDKeys = []string{"ResponseId", "RespondentId", "respondent_id", "id"}
+
+// DetectIDKey returns the key of the first question named like one of
+// IDKeys, ignoring case, or an empty string if there is none.
+func DetectIDKey(schema Schema) string {
+    for _, name := range IDKeys {
+        for _, entry := range schema {
+            if strings.EqualFold(entry.Key, name) && (entry.QType == TE || entry.QType == NUM || entry.QType == SC) {
+                return entry.Key
+            }
+        }
+    }
+    return ""
+}
+
+// SetIDKey selects the question holding the respondent IDs. An empty key
+// identifies responses by their row number.
+func (sd *SurveyData) SetIDKey(key string) error {
+    if key != "" {
+        entry, ok := sd.Schema.Get(key)
+        if !ok {
+            return fmt.Errorf("question %q not found", key)
+        }
+        if entry.QType != TE && entry.QType != NUM && entry.QType != SC {
+            return fmt.Errorf("question %q can't hold respondent IDs", key)
+        }
+    }
+    sd.IDKey = key
+    sd.idIndex = nil
+    return nil
+}
+
+// ID returns the ID of a response: the value of the IDKey question, or
+// the row number starting at 1 if the data has no ID question. Responses
+// without a value have an empty ID.
+func (sd *SurveyData) ID(row int) string {
+    if sd.IDKey == "" {
+        return strconv.Itoa(row + 1)
+    }
+    s, _ := sd.Value(row, sd.IDKey).AsString()
+    return s
+}
+
+// RowByID returns the row of the response with the given ID. If several
+// responses share the ID, the first one is returned.
+func (sd *SurveyData) RowByID(id string) (int, bool) {
+    if sd.IDKey == "" {
+        n, err := strconv.Atoi(id)
+        if err != nil || n < 1 || n > sd.n {
+            return 0, false
+        }
+        return n - 1, true
+    }
+    if sd.idIndex == nil {
+        sd.idIndex = make(map[string]int, sd.n)
+        for row := sd.n - 1; row >= 0; row-- {
+            if id := sd.ID(row); id != "" {
+                sd.idIndex[id] = row
+            }
+        }
+    }
+    row, ok := sd.idIndex[id]
+    return row, ok
+}
+
+// IDRange selects the responses whose ID lies between From and To,
+// inclusive; a single ID has From == To. IDs are compared as numbers if
+// both ends and the ID are numbers, as strings otherwise.
+type IDRange struct {
+    From, To string
+}
+
+func (r IDRange) contains(id string) bool {
+    if r.From == r.To {
+        return id == r.From
+    }
+    from, err1 := strconv.ParseFloat(r.From, 64)
+    to, err2 := strconv.ParseFloat(r.To, 64)
+    v, err3 := strconv.ParseFloat(id, 64)
+    if err1 == nil && err2 == nil && err3 == nil {
+        return from <= v && v <= to
+    }
+    return r.From <= id && id <= r.To
+}
+
+func (r IDRange) String() string {
+    if r.From == r.To {
+        return r.From
+    }
+    return r.From + ".." + r.To
+}
@@ -0,0 +1,89 @@
+package survey
+
+import (
+    "reflect"
+    "testing"
+)
+
+func idTestData() *SurveyData {
+    return NewSurveyData(
+        Schema{
+            {Key: "ResponseId", Text: "ID", QType: NUM},
+            {Key: "Q1", Text: "Color", QType: SC},
+        },
+        []Response{
+            {"ResponseId": {Val: 17.0}, "Q1": {Val: "red"}},
+            {"ResponseId": {Val: 42.0}, "Q1": {Val: "blue"}},
+            {"ResponseId": {Val: 100.0}, "Q1": {Val: "red"}},
+            {"ResponseId": {Val: 9.0}, "Q1": {Val: nil}},
+            {"ResponseId": {Val: 42.0}, "Q1": {Val: "green"}},
+        },
+    )
+}
+
+func TestSurveyData_ID(t *testing.T) {
+    sd := idTestData()
+    if sd.IDKey != "ResponseId" {
+        t.Fatalf("IDKey = %q, want ResponseId", sd.IDKey)
+    }
+    if got := sd.ID(2); got != "100" {
+        t.Errorf("ID(2) = %q, want 100", got)
+    }
+    if row, ok := sd.RowByID("42"); !ok || row != 1 {
+        t.Errorf("RowByID(42) = %d, %v; want the first match, row 1", row, ok)
+    }
+    if _, ok := sd.RowByID("43"); ok {
+        t.Error("RowByID(43) should not match")
+    }
+    if _, ok := findDiagnostic(sd.Validate(), DiagDuplicateID, "ResponseId"); !ok {
+        t.Error("missing diagnostic for the duplicate ID 42")
+    }
+
+    // Without an ID question, responses are numbered from 1
+    if err := sd.SetIDKey(""); err != nil {
+        t.Fatal(err)
+    }
+    if got := sd.ID(2); got != "3" {
+        t.Errorf("ID(2) by row = %q, want 3", got)
+    }
+    if row, ok := sd.RowByID("5"); !ok || row != 4 {
+        t.Errorf("RowByID(5) by row = %d, %v", row, ok)
+    }
+    if _, ok := sd.RowByID("6"); ok {
+        t.Error("RowByID(6) should be out of range")
+    }
+    if err := sd.SetIDKey("Missing"); err == nil {
+        t.Error("expected error for an unknown ID question")
+    }
+}
+
+func TestResponseQuery_SelectIDs(t *testing.T) {
+    sd := idTestData()
+    tests := []struct {
+        query string
+        want  []int
+    }{
+        {"ids: 42", []int{1, 4}},
+        {"ids: 9, 100", []int{2, 3}},
+        {"ids: 20..100", []int{1, 2, 4}}, // numeric, not string order
+        {"ids: 20..100; range: [first..first+1]", []int{1, 2}},
+        {"ids: 1000", []int{}},
+        {"range: [1..2]", []int{1, 2}},
+    }
+    for _, tt := range tests {
+        q, err := ParseResponseQuery(tt.query)
+        if err != nil {
+            t.Errorf("ParseResponseQuery(%q) failed: %v", tt.query, err)
+            continue
+        }
+        if got, err := q.Select(sd, sd.Rows()); err != nil || !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%q selects %v, %v; want %v", tt.query, got, err, tt.want)
+        }
+    }
+
+    for _, bad := range []string{"ids:", "ids: 5..", "ids: 1..5..9", `ids: "5"x`} {
+        if _, err := ParseResponseQuery(bad); err == nil {
+            t.Errorf("ParseResponseQuery(%q) should fail", bad)
+        }
+    }
+}
@@ -0,0 +1,123 @@
+package survey
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "strings"
+)
+
+// Besides plain keys and block references, the keys section of a
+// ResponseQuery accepts selectors that are expanded against the schema:
+//
+//    Language*, *WorkedWith, Q?   glob patterns (* any text, ? one character)
+//    /^AI.*Use$/                  regular expressions, matched anywhere in the key
+//    :MC                          all questions of a type
+//    -TE*, -:TE, -@AI             exclusions of any of the above
+//
+// A key that exists in the schema is always taken literally.
+const (
+    // TypePrefix marks a key selector that selects all questions of a type.
+    TypePrefix = ":"
+    // ExcludePrefix marks a key selector whose keys are left out.
+    ExcludePrefix = "-"
+)
+
+var questionTypes = []QuestionType{SC, MC, TE, NUM, MATRIX}
+
+// checkKeySelector reports syntax errors in a key selector of the query,
+// before the selector is expanded against a schema.
+func checkKeySelector(sel string) error {
+    sel = strings.TrimPrefix(sel, ExcludePrefix)
+    if t, ok := strings.CutPrefix(sel, TypePrefix); ok {
+        if !slices.Contains(questionTypes, QuestionType(strings.ToUpper(t))) {
+            return fmt.Errorf("unknown question type %q in %q", t, sel)
+        }
+        return nil
+    }
+    _, err := keyPattern(sel, false)
+    return err
+}
+
+// keyPattern returns the regular expression of a glob or regex selector,
+// or nil if the selector is a plain key. With fold, the expression ignores
+// case.
+func keyPattern(sel string, fold bool) (*regexp.Regexp, error) {
+    flags := ""
+    if fold {
+        flags = "(?i)"
+    }
+    if len(sel) >= 2 && strings.HasPrefix(sel, "/") && strings.HasSuffix(sel, "/") {
+        re, err := regexp.Compile(flags + sel[1:len(sel)-1])
+        if err != nil {
+            return nil, fmt.Errorf("invalid regular expression %q: %w", sel, err)
+        }
+        return re, nil
+    }
+    if !strings.ContainsAny(sel, "*?") {
+        return nil, nil
+    }
+    var sb strings.Builder
+    sb.WriteString(flags + "^")
+    for _, r := range sel {
+        switch r {
+        case '*':
+            sb.WriteString(".*")
+        case '?':
+            sb.WriteString(".")
+        default:
+            sb.WriteString(regexp.QuoteMeta(string(r)))
+        }
+    }
+    sb.WriteString("$")
+    return regexp.MustCompile(sb.String()), nil
+}
+
+// selectKeys expands a selector into question keys, replacing matrix
+// questions by their rows. isPattern reports whether the selector is a
+// pattern, block or type rather than a single key; unknown single keys are
+// returned as they are. With fold, patterns match keys ignoring case.
+func selectKeys(schema Schema, sel string, fold bool) (keys []string, isPattern bool, err error) {
+    add := func(entry *SchemaEntry) {
+        if entry.QType == MATRIX {
+            keys = append(keys, entry.Items...)
+        } else {
+            keys = append(keys, entry.Key)
+        }
+    }
+    if entry, ok := schema.Get(sel); ok {
+        add(entry)
+        return keys, false, nil
+    }
+    if name, ok := strings.CutPrefix(sel, BlockPrefix); ok {
+        entries, ok := schema.Block(name)
+        if !ok {
+            return nil, true, fmt.Errorf("unknown block %q", name)
+        }
+        for _, entry := range entries {
+            keys = append(keys, entry.Key)
+        }
+        return keys, true, nil
+    }
+    if t, ok := strings.CutPrefix(sel, TypePrefix); ok {
+        for _, entry := range schema {
+            if strings.EqualFold(string(entry.QType), t) {
+                add(entry)
+            }
+        }
+        return keys, true, nil
+    }
+    re, err := keyPattern(sel, fold)
+    if err != nil {
+        return nil, true, err
+    }
+    if re == nil {
+        return []string{sel}, false, nil
+    }
+    for _, entry := range schema {
+        if re.MatchString(entry.Key) {
+            add(entry)
+        }
+    }
+    return keys, true, nil
+}
@@ -0,0 +1,65 @@
+package survey
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestResponseQuery_KeySelectors(t *testing.T) {
+    schema := Schema{
+        {Key: "ResponseId", QType: NUM},
+        {Key: "LanguageHaveWorkedWith", QType: MC, Block: "Technology"},
+        {Key: "LanguageWantToWorkWith", QType: MC, Block: "Technology"},
+        {Key: "DatabaseHaveWorkedWith", QType: MC, Block: "Technology"},
+        {Key: "TECoding", QType: TE},
+        {Key: "TEOther", QType: TE},
+        {Key: "Age", QType: SC, Block: "Basic information"},
+        {Key: "K", QType: MATRIX, Items: []string{"K_1", "K_2"}},
+        {Key: "K_1", QType: SC, Matrix: "K"},
+        {Key: "K_2", QType: SC, Matrix: "K"},
+        {Key: "-x", QType: SC},
+    }
+    tests := []struct {
+        keys string
+        want []string
+    }{
+        {"Language*", []string{"LanguageHaveWorkedWith", "LanguageWantToWorkWith"}},
+        {"*WorkedWith", []string{"LanguageHaveWorkedWith", "DatabaseHaveWorkedWith"}},
+        {"K_?, Age", []string{"K_1", "K_2", "Age"}},
+        {"/Want|Database/", []string{"LanguageWantToWorkWith", "DatabaseHaveWorkedWith"}},
+        {":MC, -Database*", []string{"LanguageHaveWorkedWith", "LanguageWantToWorkWith"}},
+        {":matrix", []string{"K_1", "K_2"}},
+        {"-TE*, -:MC, -K, -ResponseId", []string{"Age", "-x"}},
+        {`@Technology, -"LanguageWantToWorkWith"`, []string{"LanguageHaveWorkedWith", "DatabaseHaveWorkedWith"}},
+        {"-@Technology, -:TE, -:SC, -ResponseId", []string{}},
+        {"Age, Age*, -x", []string{"Age", "-x"}}, // existing keys are taken literally
+        {"Unknown", []string{"Unknown"}},
+    }
+    for _, tt := range tests {
+        q, err := ParseResponseQuery("keys: " + tt.keys)
+        if err != nil {
+            t.Errorf("ParseResponseQuery(%q) failed: %v", tt.keys, err)
+            continue
+        }
+        keys, err := q.ResolveKeys(schema)
+        if len(tt.want) == 0 {
+            if err == nil {
+                t.Errorf("keys %q resolve to %q, want an error for excluding all", tt.keys, keys)
+            }
+            continue
+        }
+        if err != nil || !reflect.DeepEqual(keys, tt.want) {
+            t.Errorf("keys %q resolve to %q, %v; want %q", tt.keys, keys, err, tt.want)
+        }
+    }
+
+    for _, bad := range []string{"keys: /[a-/", "keys: :FOO", "keys: -:BAR"} {
+        if _, err := ParseResponseQuery(bad); err == nil {
+            t.Errorf("ParseResponseQuery(%q) should fail", bad)
+        }
+    }
+    q, _ := ParseResponseQuery("keys: Tools*")
+    if _, err := q.ResolveKeys(schema); err == nil {
+        t.Error("expected error for a pattern that matches nothing")
+    }
+}
@@ -0,0 +1,171 @@
+package survey
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+// linkMatrices connects the MATRIX questions of a schema with their row
+// questions. Rows name their matrix in the schema's matrix column; a matrix
+// no row names takes the SC questions keyed <matrix>_<suffix> as its rows,
+// which is how the Stack Overflow export names grid columns (Knowledge_1,
+// Knowledge_2, ...). Rows without declared options get the matrix's scale
+// as their ordinal option list.
+func linkMatrices(schema Schema, ds *diagnostics) {
+    for _, entry := range schema {
+        if entry.Matrix == "" {
+            continue
+        }
+        parent, ok := schema.Get(entry.Matrix)
+        switch {
+        case !ok || parent.QType != MATRIX:
+            ds.add(SeverityWarning, DiagUnknownMatrix, entry.Key, 0,
+                "question %q is a row of %q, which is not a matrix question", entry.Key, entry.Matrix)
+            entry.Matrix = ""
+        case entry.QType != SC:
+            ds.add(SeverityError, DiagMatrixRowType, entry.Key, 0,
+                "matrix row %q must be a single-choice question, not %s", entry.Key, entry.QType)
+            entry.Matrix = ""
+        default:
+            parent.Items = append(parent.Items, entry.Key)
+        }
+    }
+    for _, entry := range schema {
+        if entry.QType != MATRIX || len(entry.Items) > 0 {
+            continue
+        }
+        for _, item := range schema {
+            if item.QType == SC && item.Matrix == "" && strings.HasPrefix(item.Key, entry.Key+"_") {
+                item.Matrix = entry.Key
+                entry.Items = append(entry.Items, item.Key)
+            }
+        }
+        if len(entry.Items) == 0 {
+            ds.add(SeverityWarning, DiagEmptyMatrix, entry.Key, 0,
+                "matrix question %q has no row questions", entry.Key)
+        }
+    }
+    for _, entry := range schema {
+        if entry.Matrix == "" || len(entry.Options) > 0 {
+            continue
+        }
+        if parent, _ := schema.Get(entry.Matrix); len(parent.Scale) > 0 {
+            entry.Options = parent.Scale
+            entry.Ordinal = true
+        }
+    }
+}
+
+// MatrixRow summarizes the answers to one row question of a matrix. Counts
+// and Shares are indexed like the scale of the MatrixAnalysis.
+type MatrixRow struct {
+    Entry    *SchemaEntry
+    Counts   []int
+    Shares   []float64 // weighted share of each scale point among the answers on the scale
+    Answered int       // responses with an answer on the scale
+    Other    int       // answers that are not on the declared scale
+    NA       int
+    // Mean is the weighted mean scale position, 1 being the lowest point.
+    // TopBox is the weighted share of the two highest points. Both are NaN
+    // if the scale isn't declared or nobody answered on the scale.
+    Mean   float64
+    TopBox float64
+}
+
+// MatrixAnalysis holds the distributions of all rows of a matrix question
+// on their shared scale.
+type MatrixAnalysis struct {
+    Entry *SchemaEntry
+    // Scale is the declared scale of the matrix, lowest first. If none is
+    // declared, it is the sorted union of the options used in the rows and
+    // Ordinal is false.
+    Scale   []string
+    Ordinal bool
+    Rows    []MatrixRow
+}
+
+// Matrix analyzes the rows of a matrix question over the given rows, or
+// over all responses if rows is nil. Answers are matched to the scale
+// ignoring case.
+func (sd *SurveyData) Matrix(key string, rows []int) (*MatrixAnalysis, error) {
+    entry, ok := sd.Schema.Get(key)
+    if !ok {
+        return nil, fmt.Errorf("question %q not found", key)
+    }
+    if entry.QType != MATRIX {
+        return nil, fmt.Errorf("question %q is not a matrix question", key)
+    }
+    m := &MatrixAnalysis{Entry: entry, Scale: entry.Scale, Ordinal: len(entry.Scale) > 0}
+    if !m.Ordinal {
+        for _, itemKey := range entry.Items {
+            if item, ok := sd.Schema.Get(itemKey); ok {
+                for _, opt := range item.UsedOptions {
+                    if !containsFold(m.Scale, opt) {
+                        m.Scale = append(m.Scale, opt)
+                    }
+                }
+            }
+        }
+        sort.Strings(m.Scale)
+    }
+    index := make(map[string]int, len(m.Scale))
+    for i, point := range m.Scale {
+        index[strings.ToLower(point)] = i
+    }
+
+    k := len(m.Scale)
+    for _, itemKey := range entry.Items {
+        dist, err := sd.Distribution(itemKey, rows)
+        if err != nil {
+            return nil, err
+        }
+        row := MatrixRow{
+            Entry:  dist.Entry,
+            Counts: make([]int, k),
+            Shares: make([]float64, k),
+            NA:     dist.NA,
+            Mean:   math.NaN(),
+            TopBox: math.NaN(),
+        }
+        weighted := make([]float64, k)
+        total := 0.0
+        for code, opt := range dist.Entry.UsedOptions {
+            i, ok := index[strings.ToLower(opt)]
+            if !ok {
+                row.Other += dist.Counts[code]
+                continue
+            }
+            row.Counts[i] += dist.Counts[code]
+            row.Answered += dist.Counts[code]
+            weighted[i] += dist.Weighted[code]
+            total += dist.Weighted[code]
+        }
+        if total > 0 {
+            sum, top := 0.0, 0.0
+            for i, w := range weighted {
+                row.Shares[i] = w / total
+                sum += float64(i+1) * w
+                if i >= k-2 {
+                    top += w
+                }
+            }
+            if m.Ordinal {
+                row.Mean = sum / total
+                row.TopBox = top / total
+            }
+        }
+        m.Rows = append(m.Rows, row)
+    }
+    return m, nil
+}
+
+func containsFold(list []string, s string) bool {
+    for _, v := range list {
+        if strings.EqualFold(v, s) {
+            return true
+        }
+    }
+    return false
+}
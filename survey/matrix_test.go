@@ -0,0 +1,92 @@
+package survey
+
+import (
+    "math"
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+func TestSurveyData_Matrix(t *testing.T) {
+    dir := t.TempDir()
+    dataFile := filepath.Join(dir, "data.csv")
+    writeCSV(t, filepath.Join(dir, "data_schema.csv"), [][]string{
+        {"column", "question_text", "type", "matrix", "scale"},
+        {"K", "Rate your agreement", "MATRIX", "", "Disagree;Neutral;Agree"},
+        {"K_1", "Statement 1", "SC", "", ""},
+        {"K_2", "Statement 2", "SC", "", ""},
+        {"Freq", "How often?", "MATRIX", "", ""},
+        {"Help", "Needing help", "SC", "Freq", ""},
+        {"Count", "Count", "NUM", "Freq", ""},
+        {"Empty", "No rows", "MATRIX", "", ""},
+    })
+    writeCSV(t, dataFile, [][]string{
+        {"K_1", "K_2", "Help", "Count"},
+        {"Agree", "disagree", "Never", "1"},
+        {"Agree", "Neutral", "Daily", "2"},
+        {"Neutral", "", "Never", "3"},
+        {"Disagree", "Unsure", "", "4"},
+    })
+    sd, err := ReadSurveyData(dataFile)
+    if err != nil {
+        t.Fatalf("ReadSurveyData failed: %v", err)
+    }
+
+    k, _ := sd.Schema.Get("K")
+    if !slices.Equal(k.Items, []string{"K_1", "K_2"}) {
+        t.Errorf("K items = %q, want rows found by key prefix", k.Items)
+    }
+    freq, _ := sd.Schema.Get("Freq")
+    if !slices.Equal(freq.Items, []string{"Help"}) {
+        t.Errorf("Freq items = %q, want [Help]", freq.Items)
+    }
+    for _, want := range []struct{ kind, key string }{
+        {DiagMatrixRowType, "Count"},
+        {DiagEmptyMatrix, "Empty"},
+    } {
+        if _, ok := findDiagnostic(sd.Diagnostics, want.kind, want.key); !ok {
+            t.Errorf("missing %s diagnostic for %q", want.kind, want.key)
+        }
+    }
+    if _, ok := findDiagnostic(sd.Diagnostics, DiagMissingColumn, "K"); ok {
+        t.Error("a matrix question should not be reported as a missing column")
+    }
+
+    m, err := sd.Matrix("K", nil)
+    if err != nil {
+        t.Fatalf("Matrix failed: %v", err)
+    }
+    if !m.Ordinal || len(m.Rows) != 2 {
+        t.Fatalf("Matrix = %+v", m)
+    }
+    row := m.Rows[0]
+    if !slices.Equal(row.Counts, []int{1, 1, 2}) || row.Answered != 4 || row.NA != 0 {
+        t.Errorf("K_1 = %+v", row)
+    }
+    if math.Abs(row.Mean-2.25) > 1e-9 || math.Abs(row.TopBox-0.75) > 1e-9 {
+        t.Errorf("K_1 mean = %v, top box = %v; want 2.25, 0.75", row.Mean, row.TopBox)
+    }
+    // Answers match the scale ignoring case; others are counted apart
+    row = m.Rows[1]
+    if !slices.Equal(row.Counts, []int{1, 1, 0}) || row.Other != 1 || row.NA != 1 {
+        t.Errorf("K_2 = %+v", row)
+    }
+
+    // Without a declared scale there is no mean
+    m, err = sd.Matrix("Freq", nil)
+    if err != nil {
+        t.Fatalf("Matrix failed: %v", err)
+    }
+    if m.Ordinal || !slices.Equal(m.Scale, []string{"Daily", "Never"}) || !math.IsNaN(m.Rows[0].Mean) {
+        t.Errorf("Freq matrix = %+v", m)
+    }
+    if _, err := sd.Matrix("Help", nil); err == nil {
+        t.Error("expected error for a question that is not a matrix")
+    }
+
+    q, _ := ParseResponseQuery("keys: K, K_1, Help")
+    keys, err := q.ResolveKeys(sd.Schema)
+    if err != nil || !slices.Equal(keys, []string{"K_1", "K_2", "Help"}) {
+        t.Errorf("ResolveKeys = %q, %v", keys, err)
+    }
+}
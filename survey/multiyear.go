@@ -0,0 +1,154 @@
+package survey
+
+import (
+    "encoding/csv"
+    "fmt"
+    "os"
+    "slices"
+    "strings"
+)
+
+// KeyMap reconciles question keys that were renamed between survey years.
+// It maps a canonical key to the key used in each year; years that aren't
+// listed use the canonical key.
+type KeyMap map[string]map[string]string
+
+// Key returns the key the given year uses for a canonical key.
+func (km KeyMap) Key(canonical, year string) string {
+    if key, ok := km[canonical][year]; ok {
+        return key
+    }
+    return canonical
+}
+
+// LoadKeyMap reads a key mapping file. It is a CSV file with a header row
+// "canonical,<year>,<year>,..." followed by one row per renamed question
+// holding the canonical key and the key used in each year. Empty cells
+// mean the year uses the canonical key.
+func LoadKeyMap(filename string) (KeyMap, error) {
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    cr := csv.NewReader(f)
+    cr.FieldsPerRecord = -1
+    rows, err := cr.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("failed to read key map %s: %w", filename, err)
+    }
+    if len(rows) == 0 {
+        return nil, fmt.Errorf("key map %s is empty", filename)
+    }
+    years := rows[0]
+    km := make(KeyMap)
+    for i, row := range rows[1:] {
+        if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+            continue
+        }
+        canonical := strings.TrimSpace(row[0])
+        if _, exists := km[canonical]; exists {
+            return nil, fmt.Errorf("key map %s line %d: duplicate key %q", filename, i+2, canonical)
+        }
+        km[canonical] = make(map[string]string)
+        for j := 1; j < len(row) && j < len(years); j++ {
+            if key := strings.TrimSpace(row[j]); key != "" {
+                km[canonical][strings.TrimSpace(years[j])] = key
+            }
+        }
+    }
+    return km, nil
+}
+
+// YearData is the dataset of one survey year.
+type YearData struct {
+    Label string
+    Data  *SurveyData
+    Rows  []int // the responses to compare, nil for all
+}
+
+// MultiYear holds the datasets of several survey years for comparison.
+type MultiYear struct {
+    Years []YearData
+    Keys  KeyMap
+}
+
+// Trend holds the option shares of one question across survey years.
+// Counts and Shares are indexed by option, then by year.
+type Trend struct {
+    Key     string
+    QType   QuestionType
+    Years   []string
+    Asked   []bool // whether the year's dataset contains the question
+    Options []string
+    Counts  [][]int
+    Shares  [][]float64
+    // Offered tells whether an option occurs in a year at all, so that
+    // options missing from a year can be told apart from zero counts
+    Offered     [][]bool
+    Respondents []int
+}
+
+// Trend computes the distribution of a question in every year. Options
+// are the union of all years' options, in order of first appearance.
+// Shares are relative to the respondents who answered the question and use
+// the respondent weights of years that have them.
+func (m *MultiYear) Trend(key string) (*Trend, error) {
+    t := &Trend{Key: key}
+    dists := make([]*Distribution, len(m.Years))
+    for i, year := range m.Years {
+        t.Years = append(t.Years, year.Label)
+        yearKey := m.Keys.Key(key, year.Label)
+        if _, ok := year.Data.Schema.Get(yearKey); !ok {
+            t.Asked = append(t.Asked, false)
+            continue
+        }
+        dist, err := year.Data.Distribution(yearKey, year.Rows)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", year.Label, err)
+        }
+        if t.QType == "" {
+            t.QType = dist.Entry.QType
+        }
+        dists[i] = dist
+        t.Asked = append(t.Asked, true)
+        for _, opt := range dist.Entry.UsedOptions {
+            if !slices.Contains(t.Options, opt) {
+                t.Options = append(t.Options, opt)
+            }
+        }
+    }
+    if t.QType == "" {
+        return nil, fmt.Errorf("question %q not found in any year", key)
+    }
+
+    t.Respondents = make([]int, len(m.Years))
+    for i, dist := range dists {
+        if dist != nil {
+            t.Respondents[i] = dist.Respondents
+        }
+    }
+    for _, opt := range t.Options {
+        counts := make([]int, len(m.Years))
+        shares := make([]float64, len(m.Years))
+        offered := make([]bool, len(m.Years))
+        for i, dist := range dists {
+            if dist == nil {
+                continue
+            }
+            code := slices.Index(dist.Entry.UsedOptions, opt)
+            if code < 0 {
+                continue
+            }
+            offered[i] = true
+            counts[i] = dist.Counts[code]
+            if dist.WeightedRespondents > 0 {
+                shares[i] = dist.Weighted[code] / dist.WeightedRespondents
+            }
+        }
+        t.Counts = append(t.Counts, counts)
+        t.Shares = append(t.Shares, shares)
+        t.Offered = append(t.Offered, offered)
+    }
+    return t, nil
+}
@@ -0,0 +1,97 @@
+package survey
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestLoadKeyMap(t *testing.T) {
+    filename := filepath.Join(t.TempDir(), "keys.csv")
+    content := "canonical,2022,2023,2024\nAISelect,,AIUse,\nEmployment,Employ,,\n"
+    if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+    km, err := LoadKeyMap(filename)
+    if err != nil {
+        t.Fatalf("LoadKeyMap failed: %v", err)
+    }
+    tests := []struct{ canonical, year, want string }{
+        {"AISelect", "2023", "AIUse"},
+        {"AISelect", "2024", "AISelect"},
+        {"Employment", "2022", "Employ"},
+        {"Country", "2022", "Country"},
+    }
+    for _, tt := range tests {
+        if got := km.Key(tt.canonical, tt.year); got != tt.want {
+            t.Errorf("Key(%q, %q) = %q, want %q", tt.canonical, tt.year, got, tt.want)
+        }
+    }
+}
+
+func TestMultiYear_Trend(t *testing.T) {
+    y2023 := NewSurveyData(
+        Schema{{Key: "Lang", Text: "Language", QType: MC}},
+        []Response{
+            {"Lang": {Val: []string{"Go", "Perl"}}},
+            {"Lang": {Val: []string{"Go"}}},
+            {"Lang": {Val: nil}},
+        },
+    )
+    y2024 := NewSurveyData(
+        Schema{{Key: "Language", Text: "Language", QType: MC}},
+        []Response{
+            {"Language": {Val: []string{"Go", "Rust"}}},
+            {"Language": {Val: []string{"Rust"}}},
+            {"Language": {Val: []string{"Rust"}}},
+            {"Language": {Val: []string{"Go"}}},
+        },
+    )
+    y2022 := NewSurveyData(Schema{{Key: "Other", Text: "Other", QType: SC}}, nil)
+
+    my := &MultiYear{
+        Years: []YearData{{Label: "2022", Data: y2022}, {Label: "2023", Data: y2023}, {Label: "2024", Data: y2024}},
+        Keys:  KeyMap{"Language": {"2023": "Lang"}},
+    }
+    trend, err := my.Trend("Language")
+    if err != nil {
+        t.Fatalf("Trend failed: %v", err)
+    }
+    if !reflect.DeepEqual(trend.Asked, []bool{false, true, true}) {
+        t.Errorf("Asked = %v, want [false true true]", trend.Asked)
+    }
+    if !reflect.DeepEqual(trend.Options, []string{"Go", "Perl", "Rust"}) {
+        t.Errorf("Options = %v, want [Go Perl Rust]", trend.Options)
+    }
+    if !reflect.DeepEqual(trend.Respondents, []int{0, 2, 4}) {
+        t.Errorf("Respondents = %v, want [0 2 4]", trend.Respondents)
+    }
+    if !reflect.DeepEqual(trend.Counts, [][]int{{0, 2, 2}, {0, 1, 0}, {0, 0, 3}}) {
+        t.Errorf("Counts = %v", trend.Counts)
+    }
+    if !reflect.DeepEqual(trend.Offered[2], []bool{false, false, true}) {
+        t.Errorf("Rust offered = %v, want [false false true]", trend.Offered[2])
+    }
+    if got := trend.Shares[0][1]; got != 1.0 {
+        t.Errorf("Go share in 2023 = %v, want 1", got)
+    }
+    if got := trend.Shares[2][2]; got != 0.75 {
+        t.Errorf("Rust share in 2024 = %v, want 0.75", got)
+    }
+
+    if _, err := my.Trend("Missing"); err == nil {
+        t.Error("expected error for a question missing in all years")
+    }
+
+    // Rows restrict a year to the selected responses
+    my.Years[2].Rows = []int{0, 3}
+    trend, err = my.Trend("Language")
+    if err != nil {
+        t.Fatalf("Trend with rows failed: %v", err)
+    }
+    if trend.Respondents[2] != 2 || trend.Counts[0][2] != 2 || trend.Counts[2][2] != 1 {
+        t.Errorf("2024 with rows: %d respondents, Go %d, Rust %d; want 2, 2, 1",
+            trend.Respondents[2], trend.Counts[0][2], trend.Counts[2][2])
+    }
+}
@@ -0,0 +1,311 @@
+package survey
+
+import (
+    "encoding/csv"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// Normalization rules clean up option values of SC and MC questions while
+// the survey data is read. The rules file is a CSV file with the columns
+// "question,rule,value,canonical"; question "*" applies a rule to all
+// questions. Rules:
+//
+//    trim   strip leading and trailing whitespace
+//    fold   merge options that differ only in case into the first one seen
+//    alias  replace the option value by canonical
+//
+// Trimming is applied first, then aliases, then case folding. With fold
+// active, aliases match regardless of case.
+
+// optionRules are the normalization rules of one question.
+type optionRules struct {
+    trim    bool
+    fold    bool
+    aliases map[string]string
+}
+
+// NormalizationRules holds the rules of a normalization rules file.
+type NormalizationRules struct {
+    Filename string
+    rules    map[string]*optionRules // by question key, "*" for all
+}
+
+// DefaultRulesFilename returns the normalization rules file used for a
+// data file if none is given: so_2024_raw.xlsx -> so_2024_raw_rules.csv.
+func DefaultRulesFilename(dataFile string) string {
+    return strings.TrimSuffix(dataFile, filepath.Ext(dataFile)) + "_rules.csv"
+}
+
+// LoadNormalizationRules reads a normalization rules file. A header row
+// starting with "question" is skipped.
+func LoadNormalizationRules(filename string) (*NormalizationRules, error) {
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    cr := csv.NewReader(f)
+    cr.FieldsPerRecord = -1
+    cr.Comment = '#'
+    records, err := cr.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("failed to read rules %s: %w", filename, err)
+    }
+    nr := &NormalizationRules{Filename: filename, rules: make(map[string]*optionRules)}
+    for i, rec := range records {
+        if len(rec) < 2 {
+            return nil, fmt.Errorf("rules %s line %d: expected question and rule", filename, i+1)
+        }
+        key, rule := strings.TrimSpace(rec[0]), strings.ToLower(strings.TrimSpace(rec[1]))
+        if i == 0 && strings.EqualFold(key, "question") {
+            continue
+        }
+        r := nr.rules[key]
+        if r == nil {
+            r = &optionRules{aliases: make(map[string]string)}
+            nr.rules[key] = r
+        }
+        switch rule {
+        case "trim":
+            r.trim = true
+        case "fold":
+            r.fold = true
+        case "alias":
+            if len(rec) < 4 || rec[2] == "" {
+                return nil, fmt.Errorf("rules %s line %d: alias needs a value and a canonical option", filename, i+1)
+            }
+            r.aliases[rec[2]] = rec[3]
+        default:
+            return nil, fmt.Errorf("rules %s line %d: unknown rule %q", filename, i+1, rule)
+        }
+    }
+    return nr, nil
+}
+
+// AppendAliasRule adds an alias rule to a rules file, creating the file
+// with a header row if it doesn't exist.
+func AppendAliasRule(filename, key, from, to string) error {
+    _, statErr := os.Stat(filename)
+    f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+    if err != nil {
+        return err
+    }
+    w := csv.NewWriter(f)
+    if os.IsNotExist(statErr) {
+        w.Write([]string{"question", "rule", "value", "canonical"})
+    }
+    w.Write([]string{key, "alias", from, to})
+    w.Flush()
+    if err := w.Error(); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// normalizer returns a function normalizing the option values of the given
+// question, or nil if no rules apply. The function keeps state for case
+// folding and must be used for a single pass over the data.
+func (nr *NormalizationRules) normalizer(key string) func(string) string {
+    if nr == nil {
+        return nil
+    }
+    all, own := nr.rules["*"], nr.rules[key]
+    if all == nil && own == nil {
+        return nil
+    }
+    r := &optionRules{aliases: make(map[string]string)}
+    for _, src := range []*optionRules{all, own} {
+        if src == nil {
+            continue
+        }
+        r.trim = r.trim || src.trim
+        r.fold = r.fold || src.fold
+        for from, to := range src.aliases {
+            r.aliases[from] = to
+        }
+    }
+    if r.fold {
+        folded := make(map[string]string, len(r.aliases))
+        for from, to := range r.aliases {
+            folded[strings.ToLower(from)] = to
+        }
+        r.aliases = folded
+    }
+
+    firstSeen := make(map[string]string)
+    return func(val string) string {
+        if r.trim {
+            val = strings.TrimSpace(val)
+        }
+        lookup := val
+        if r.fold {
+            lookup = strings.ToLower(val)
+        }
+        if to, ok := r.aliases[lookup]; ok {
+            val = to
+        }
+        if r.fold {
+            lower := strings.ToLower(val)
+            if first, ok := firstSeen[lower]; ok {
+                return first
+            }
+            firstSeen[lower] = val
+        }
+        return val
+    }
+}
+
+// MergeOption replaces the option from of an SC or MC question by the
+// option to in all responses. If to isn't an option yet, from is renamed.
+func (sd *SurveyData) MergeOption(key, from, to string) error {
+    entry, ok := sd.Schema.Get(key)
+    if !ok {
+        return fmt.Errorf("question %q not found", key)
+    }
+    if entry.QType != SC && entry.QType != MC {
+        return fmt.Errorf("question %q is not single or multi choice", key)
+    }
+    if !slices.Contains(entry.UsedOptions, from) {
+        return fmt.Errorf("question %q has no option %q", key, from)
+    }
+    col, ok := sd.Column(key)
+    if !ok {
+        return fmt.Errorf("question %q has no data", key)
+    }
+
+    vals := make([]ResponseValue, sd.n)
+    for row := range vals {
+        vals[row] = col.Value(row)
+        if ss, ok := vals[row].AsStringSlice(); ok {
+            merged := make([]string, 0, len(ss))
+            for _, opt := range ss {
+                if opt == from {
+                    opt = to
+                }
+                if !slices.Contains(merged, opt) {
+                    merged = append(merged, opt)
+                }
+            }
+            vals[row] = ResponseValue{Val: merged}
+        } else if s, ok := vals[row].AsString(); ok && s == from {
+            vals[row] = ResponseValue{Val: to}
+        }
+    }
+    entry.resetOptions()
+    b := newColumnBuilder(entry)
+    for _, val := range vals {
+        b.append(val)
+    }
+    sd.columns[key] = b.finish()
+    return nil
+}
+
+// MergeSuggestion proposes to merge an option into a similar, more
+// frequent one.
+type MergeSuggestion struct {
+    Key       string
+    From, To  string
+    FromCount int
+    ToCount   int
+    Distance  int
+}
+
+// SuggestMerges finds pairs of options of a question whose case folded and
+// trimmed values are at most maxDistance edits apart. Pairs are only
+// suggested if the distance is less than a third of the length of the
+// shorter option and both contain the same digits, so that short or
+// numbered options ("npm"/"pnpm", "18-24"/"25-34") aren't matched. The
+// less frequent option is suggested to be merged into the more frequent
+// one, and an undeclared option into a declared one. Declared options are
+// never merged with each other, and unused options aren't suggested.
+func (sd *SurveyData) SuggestMerges(key string, maxDistance int) ([]MergeSuggestion, error) {
+    dist, err := sd.Distribution(key, nil)
+    if err != nil {
+        return nil, err
+    }
+    opts := dist.Entry.UsedOptions
+    folded := make([][]rune, len(opts))
+    for i, opt := range opts {
+        folded[i] = []rune(strings.ToLower(strings.TrimSpace(opt)))
+    }
+    var out []MergeSuggestion
+    for i := range opts {
+        for j := i + 1; j < len(opts); j++ {
+            shorter := min(len(folded[i]), len(folded[j]))
+            if abs(len(folded[i])-len(folded[j])) > maxDistance {
+                continue
+            }
+            declaredI, declaredJ := dist.Entry.Declared(opts[i]), dist.Entry.Declared(opts[j])
+            if len(dist.Entry.Options) > 0 && declaredI && declaredJ {
+                continue
+            }
+            if dist.Counts[i] == 0 || dist.Counts[j] == 0 {
+                continue
+            }
+            d := editDistance(folded[i], folded[j])
+            if d > maxDistance || 3*d >= shorter || digits(folded[i]) != digits(folded[j]) {
+                continue
+            }
+            // On a tie, keep the option that sorts first
+            from, to := j, i
+            if declaredJ && !declaredI || declaredI == declaredJ && dist.Counts[j] > dist.Counts[i] {
+                from, to = i, j
+            }
+            out = append(out, MergeSuggestion{
+                Key:       key,
+                From:      opts[from],
+                To:        opts[to],
+                FromCount: dist.Counts[from],
+                ToCount:   dist.Counts[to],
+                Distance:  d,
+            })
+        }
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Distance < out[j].Distance })
+    return out, nil
+}
+
+func digits(s []rune) string {
+    var out []rune
+    for _, r := range s {
+        if unicode.IsDigit(r) {
+            out = append(out, r)
+        }
+    }
+    return string(out)
+}
+
+func abs(x int) int {
+    if x < 0 {
+        return -x
+    }
+    return x
+}
+
+// editDistance returns the Levenshtein distance of a and b.
+func editDistance(a, b []rune) int {
+    prev := make([]int, len(b)+1)
+    cur := make([]int, len(b)+1)
+    for j := range prev {
+        prev[j] = j
+    }
+    for i := 1; i <= len(a); i++ {
+        cur[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i-1] == b[j-1] {
+                cost = 0
+            }
+            cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+        }
+        prev, cur = cur, prev
+    }
+    return prev[len(b)]
+}
@@ -0,0 +1,102 @@
+package survey
+
+import (
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestReadSurveyData_NormalizationRules(t *testing.T) {
+    dir := t.TempDir()
+    dataFile := filepath.Join(dir, "data.csv")
+    writeCSV(t, filepath.Join(dir, "data_schema.csv"), [][]string{
+        {"column", "question_text", "type"},
+        {"Lang", "Languages", "MC"},
+        {"OS", "Operating system", "SC"},
+    })
+    writeCSV(t, dataFile, [][]string{
+        {"Lang", "OS"},
+        {"Go;golang ", "Linux "},
+        {"GO;Rust", "linux"},
+        {"Rust", "Mac OS"},
+    })
+    rulesFile := filepath.Join(dir, "rules.csv")
+    writeCSV(t, rulesFile, [][]string{
+        {"question", "rule", "value", "canonical"},
+        {"*", "trim"},
+        {"Lang", "fold"},
+        {"Lang", "alias", "golang", "Go"},
+        {"OS", "alias", "Mac OS", "macOS"},
+    })
+
+    sd, err := ReadSurveyDataWithOptions(dataFile, ReadOptions{RulesFile: rulesFile})
+    if err != nil {
+        t.Fatalf("ReadSurveyDataWithOptions failed: %v", err)
+    }
+    lang, _ := sd.Schema.Get("Lang")
+    if !reflect.DeepEqual(lang.UsedOptions, []string{"Go", "Rust"}) {
+        t.Errorf("Lang UsedOptions = %v, want [Go Rust]", lang.UsedOptions)
+    }
+    if got := sd.Value(0, "Lang"); !reflect.DeepEqual(got.Val, []string{"Go"}) {
+        t.Errorf("Lang row 0 = %v, want [Go]", got.Val)
+    }
+    // OS is trimmed but not case folded
+    osEntry, _ := sd.Schema.Get("OS")
+    if !reflect.DeepEqual(osEntry.UsedOptions, []string{"Linux", "linux", "macOS"}) {
+        t.Errorf("OS UsedOptions = %v, want [Linux linux macOS]", osEntry.UsedOptions)
+    }
+    if len(sd.Sources) != 3 || sd.Sources[2].Path != rulesFile {
+        t.Errorf("Sources = %v, want the rules file last", sd.Sources)
+    }
+    if sd.RulesFile != rulesFile {
+        t.Errorf("RulesFile = %q, want %q", sd.RulesFile, rulesFile)
+    }
+
+    suggestions, err := sd.SuggestMerges("OS", 2)
+    if err != nil {
+        t.Fatalf("SuggestMerges failed: %v", err)
+    }
+    want := []MergeSuggestion{{Key: "OS", From: "linux", To: "Linux", FromCount: 1, ToCount: 1, Distance: 0}}
+    if !reflect.DeepEqual(suggestions, want) {
+        t.Errorf("SuggestMerges = %+v, want %+v", suggestions, want)
+    }
+
+    if err := sd.MergeOption("OS", "linux", "Linux"); err != nil {
+        t.Fatalf("MergeOption failed: %v", err)
+    }
+    if !reflect.DeepEqual(osEntry.UsedOptions, []string{"Linux", "macOS"}) {
+        t.Errorf("OS UsedOptions after merge = %v", osEntry.UsedOptions)
+    }
+    if got := sd.Value(1, "OS"); got.Val != "Linux" {
+        t.Errorf("OS row 1 after merge = %v, want Linux", got.Val)
+    }
+
+    if err := AppendAliasRule(rulesFile, "OS", "linux", "Linux"); err != nil {
+        t.Fatalf("AppendAliasRule failed: %v", err)
+    }
+    sd, err = ReadSurveyDataWithOptions(dataFile, ReadOptions{RulesFile: rulesFile})
+    if err != nil {
+        t.Fatalf("ReadSurveyDataWithOptions failed: %v", err)
+    }
+    osEntry, _ = sd.Schema.Get("OS")
+    if !reflect.DeepEqual(osEntry.UsedOptions, []string{"Linux", "macOS"}) {
+        t.Errorf("OS UsedOptions with appended rule = %v", osEntry.UsedOptions)
+    }
+}
+
+func TestEditDistance(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want int
+    }{
+        {"", "abc", 3},
+        {"kitten", "sitting", 3},
+        {"javascript", "javascipt", 1},
+        {"go", "go", 0},
+    }
+    for _, tt := range tests {
+        if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+            t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,97 @@
+package survey
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+// Project holds analysis definitions that belong to a dataset but not to
+// its source files, such as derived questions. It is stored as JSON next
+// to the data file.
+type Project struct {
+    Filename string       `json:"-"`
+    Derived  []DerivedVar `json:"derived,omitempty"`
+}
+
+// DefaultProjectFilename returns the project file used for a data file if
+// none is given: so_2024_raw.xlsx -> so_2024_raw_project.json.
+func DefaultProjectFilename(dataFile string) string {
+    return strings.TrimSuffix(dataFile, filepath.Ext(dataFile)) + "_project.json"
+}
+
+// LoadProject reads a project file. A missing file yields an empty project
+// that is created on the first Save.
+func LoadProject(filename string) (*Project, error) {
+    p := &Project{Filename: filename}
+    b, err := os.ReadFile(filename)
+    if errors.Is(err, os.ErrNotExist) {
+        return p, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(b, p); err != nil {
+        return nil, fmt.Errorf("failed to read project %s: %w", filename, err)
+    }
+    return p, nil
+}
+
+// Save writes the project file.
+func (p *Project) Save() error {
+    b, err := json.MarshalIndent(p, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := p.Filename + ".tmp"
+    if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, p.Filename)
+}
+
+func (p *Project) derived(key string) (DerivedVar, bool) {
+    if p == nil {
+        return DerivedVar{}, false
+    }
+    for _, dv := range p.Derived {
+        if dv.Key == key {
+            return dv, true
+        }
+    }
+    return DerivedVar{}, false
+}
+
+// SetDerived adds or replaces the definition of a derived question.
+func (p *Project) SetDerived(dv DerivedVar) {
+    for i := range p.Derived {
+        if p.Derived[i].Key == dv.Key {
+            p.Derived[i] = dv
+            return
+        }
+    }
+    p.Derived = append(p.Derived, dv)
+}
+
+// RemoveDerived removes the definition of a derived question.
+func (p *Project) RemoveDerived(key string) {
+    p.Derived = slices.DeleteFunc(p.Derived, func(dv DerivedVar) bool { return dv.Key == key })
+}
+
+// ApplyProject computes the project's derived questions and attaches the
+// project to the data. Definitions that fail are skipped; their errors are
+// returned joined.
+func (sd *SurveyData) ApplyProject(p *Project) error {
+    sd.Project = p
+    var errs []error
+    for _, dv := range p.Derived {
+        if _, err := sd.Derive(dv); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
@@ -0,0 +1,167 @@
+package survey
+
+import (
+    "cmp"
+    "errors"
+    "fmt"
+    "slices"
+    "strings"
+)
+
+// QueryOptions controls how a ResponseQuery is checked against a schema.
+type QueryOptions struct {
+    // FoldKeyCase matches question keys ignoring case. Validate replaces
+    // such keys and block names with the schema's spelling, and makes
+    // glob and regex patterns of the keys section ignore case when they
+    // are resolved.
+    FoldKeyCase bool
+}
+
+// Validate checks the keys, filter, sample and sort keys of the query
+// against a schema, so that typos are reported instead of selecting
+// nothing. Unknown questions, blocks and options are errors, with
+// suggestions of similar names. Patterns are checked by ResolveKeys.
+// All problems are returned, joined into one error.
+func (rq *ResponseQuery) Validate(schema Schema, opts QueryOptions) error {
+    v := &queryValidator{schema: schema, opts: opts}
+    rq.foldKeys = opts.FoldKeyCase
+    for i, sel := range rq.Keys {
+        rq.Keys[i] = v.keySelector(sel)
+    }
+    if rq.Where != nil {
+        v.filter(rq.Where.root)
+    }
+    if rq.Sample != nil && rq.Sample.By != "" {
+        if entry := v.question(rq.Sample.By, "sample"); entry != nil {
+            rq.Sample.By = entry.Key
+        }
+    }
+    for i, key := range rq.Sort {
+        if entry := v.question(key.Key, "sort"); entry != nil {
+            rq.Sort[i].Key = entry.Key
+        }
+    }
+    return errors.Join(v.errs...)
+}
+
+type queryValidator struct {
+    schema Schema
+    opts   QueryOptions
+    errs   []error
+}
+
+// question looks up a question key, ignoring case if enabled, and records
+// an error if there is none.
+func (v *queryValidator) question(key, section string) *SchemaEntry {
+    if entry, ok := v.schema.Get(key); ok {
+        return entry
+    }
+    if v.opts.FoldKeyCase {
+        for _, entry := range v.schema {
+            if strings.EqualFold(entry.Key, key) {
+                return entry
+            }
+        }
+    }
+    keys := make([]string, len(v.schema))
+    for i, entry := range v.schema {
+        keys[i] = entry.Key
+    }
+    v.errs = append(v.errs, fmt.Errorf("%s: unknown question %q%s", section, key, didYouMean(key, keys)))
+    return nil
+}
+
+// keySelector checks a selector of the keys section and returns it with
+// the key spelled as in the schema.
+func (v *queryValidator) keySelector(sel string) string {
+    if _, ok := v.schema.Get(sel); ok {
+        return sel
+    }
+    prefix := ""
+    if rest, ok := strings.CutPrefix(sel, ExcludePrefix); ok && rest != "" {
+        prefix, sel = ExcludePrefix, rest
+    }
+    if name, ok := strings.CutPrefix(sel, BlockPrefix); ok {
+        entries, ok := v.schema.Block(name)
+        if !ok {
+            v.errs = append(v.errs, fmt.Errorf("keys: unknown block %q%s", name, didYouMean(name, v.schema.Blocks())))
+        } else if v.opts.FoldKeyCase {
+            sel = BlockPrefix + entries[0].Block
+        }
+        return prefix + sel
+    }
+    if strings.HasPrefix(sel, TypePrefix) {
+        return prefix + sel
+    }
+    if re, _ := keyPattern(sel, false); re != nil {
+        return prefix + sel
+    }
+    if entry := v.question(sel, "keys"); entry != nil {
+        sel = entry.Key
+    }
+    return prefix + sel
+}
+
+// filter checks the keys and values of the conditions of a filter.
+func (v *queryValidator) filter(node filterNode) {
+    switch n := node.(type) {
+    case *filterLogic:
+        v.filter(n.x)
+        v.filter(n.y)
+    case *filterNot:
+        v.filter(n.x)
+    case *filterCond:
+        entry := v.question(n.key, "where")
+        if entry == nil {
+            return
+        }
+        n.key = entry.Key
+        for _, value := range n.values {
+            switch entry.QType {
+            case SC, MC:
+                if !containsFold(entry.UsedOptions, value) {
+                    v.errs = append(v.errs, fmt.Errorf("where: %q is not an option of %q%s",
+                        value, entry.Key, didYouMean(value, entry.UsedOptions)))
+                }
+            case NUM:
+                if _, ok := parseNumericLabel(value); !ok {
+                    v.errs = append(v.errs, fmt.Errorf("where: %q is not a number for numeric question %q", value, entry.Key))
+                }
+            }
+        }
+    }
+}
+
+// didYouMean suggests up to three candidates close to name by edit
+// distance, ignoring case, formatted as the end of an error message. If
+// candidates only differ from name in case, only they are suggested.
+func didYouMean(name string, candidates []string) string {
+    type suggestion struct {
+        s    string
+        dist int
+    }
+    target := []rune(strings.ToLower(name))
+    limit := max(2, len(target)/3)
+    var found []suggestion
+    for _, c := range candidates {
+        if d := editDistance(target, []rune(strings.ToLower(c))); d <= limit {
+            found = append(found, suggestion{c, d})
+        }
+    }
+    if len(found) == 0 {
+        return ""
+    }
+    slices.SortStableFunc(found, func(a, b suggestion) int { return cmp.Compare(a.dist, b.dist) })
+    // A name that only differs in case is the one that was meant
+    if found[0].dist == 0 {
+        found = slices.DeleteFunc(found, func(f suggestion) bool { return f.dist > 0 })
+    }
+    quoted := make([]string, 0, 3)
+    for _, f := range found[:min(len(found), 3)] {
+        quoted = append(quoted, fmt.Sprintf("%q", f.s))
+    }
+    if len(quoted) == 1 {
+        return ", did you mean " + quoted[0] + "?"
+    }
+    return ", did you mean " + strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1] + "?"
+}
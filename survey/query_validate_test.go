@@ -0,0 +1,110 @@
+package survey
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+func TestResponseQuery_Validate(t *testing.T) {
+    schema := whereTestData().Schema
+    schema[0].Block = "Basic information"
+
+    valid := []string{
+        "keys: Country, Lang*, -:TE, @basic information, /^Y/",
+        `where: Country in (germany, France) and Lang has all (go, Rust) and Years > 3; sort: Age; sample: 2 per Country`,
+        "where: [Work mode] = anything",
+    }
+    for _, query := range valid {
+        q, err := ParseResponseQuery(query)
+        if err != nil {
+            t.Fatalf("ParseResponseQuery(%q) failed: %v", query, err)
+        }
+        if err := q.Validate(schema, QueryOptions{}); err != nil {
+            t.Errorf("Validate(%q) = %v, want no error", query, err)
+        }
+    }
+
+    tests := []struct {
+        query string
+        want  []string // parts of the error message
+    }{
+        {"keys: Contry, Age", []string{`unknown question "Contry", did you mean "Country"?`}},
+        {"keys: -Yeers", []string{`unknown question "Yeers", did you mean "Years"?`}},
+        {"keys: @Basic info", []string{`unknown block "Basic info"`}},
+        {"keys: country", []string{`did you mean "Country"?`}},
+        {"where: Country = Germny", []string{`"Germny" is not an option of "Country", did you mean "Germany"?`}},
+        {"where: Lang has any (Go, Rusty, Java)", []string{`"Rusty"`, `"Java" is not an option`}},
+        {"where: Years > ten", []string{`"ten" is not a number`}},
+        {"where: Agge is na; sort: Yaers; sample: 5 per Cuntry", []string{`where: unknown question "Agge"`, `sort: unknown question "Yaers"`, `sample: unknown question "Cuntry"`}},
+        {"keys: Xyzzy", []string{`unknown question "Xyzzy"`}},
+    }
+    for _, tt := range tests {
+        q, err := ParseResponseQuery(tt.query)
+        if err != nil {
+            t.Fatalf("ParseResponseQuery(%q) failed: %v", tt.query, err)
+        }
+        err = q.Validate(schema, QueryOptions{})
+        if err == nil {
+            t.Errorf("Validate(%q) should fail", tt.query)
+            continue
+        }
+        for _, want := range tt.want {
+            if !strings.Contains(err.Error(), want) {
+                t.Errorf("Validate(%q) = %q, want it to contain %q", tt.query, err, want)
+            }
+        }
+        if strings.Contains(err.Error(), "did you mean") && strings.Contains(tt.query, "Xyzzy") {
+            t.Errorf("Validate(%q) suggests unrelated keys: %v", tt.query, err)
+        }
+    }
+
+    // Keys are matched ignoring case on request and spelled as in the schema
+    q, err := ParseResponseQuery("keys: country, -YEARS; where: lang has go; sort: age desc; sample: 1 per COUNTRY")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := q.Validate(schema, QueryOptions{FoldKeyCase: true}); err != nil {
+        t.Fatalf("Validate ignoring case failed: %v", err)
+    }
+    want := "keys: Country, -Years; where: Lang has go; sample: 1 per Country; sort: Age desc"
+    if got := q.String(); got != want {
+        t.Errorf("validated query = %q, want %q", got, want)
+    }
+    if !slices.Equal(q.Keys, []string{"Country", "-Years"}) {
+        t.Errorf("Keys = %q", q.Keys)
+    }
+
+    // So are block names, and patterns then ignore case too
+    q, err = ParseResponseQuery("keys: @BASIC information, la*, /^y/, -EXP*")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := q.ResolveKeys(schema); err == nil {
+        t.Error("la* should match no questions without folding case")
+    }
+    if err := q.Validate(schema, QueryOptions{FoldKeyCase: true}); err != nil {
+        t.Fatalf("Validate ignoring case failed: %v", err)
+    }
+    if want := "keys: @Basic information, la*, /^y/, -EXP*"; q.String() != want {
+        t.Errorf("validated query = %q, want %q", q.String(), want)
+    }
+    if keys, err := q.ResolveKeys(schema); err != nil || !slices.Equal(keys, []string{"Country", "Lang", "Years"}) {
+        t.Errorf("ResolveKeys ignoring case = %q, %v; want [Country Lang Years]", keys, err)
+    }
+}
+
+func TestDidYouMean(t *testing.T) {
+    candidates := []string{"Age", "Agent", "Page", "Country", "LanguageHaveWorkedWith"}
+    tests := []struct{ name, want string }{
+        {"age", `, did you mean "Age"?`},
+        {"Agee", `, did you mean "Age", "Agent" or "Page"?`},
+        {"LanguageHaveWorkdWith", `, did you mean "LanguageHaveWorkedWith"?`},
+        {"Salary", ""},
+    }
+    for _, tt := range tests {
+        if got := didYouMean(tt.name, candidates); got != tt.want {
+            t.Errorf("didYouMean(%q) = %q, want %q", tt.name, got, tt.want)
+        }
+    }
+}
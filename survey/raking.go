@@ -0,0 +1,233 @@
+package survey
+
+import (
+    "encoding/csv"
+    "fmt"
+    "math"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// RakeTarget holds the target shares of the options of one SC question.
+type RakeTarget struct {
+    Key    string
+    Shares map[string]float64
+}
+
+// LoadRakeTargets reads raking targets from a CSV file with the columns
+// "question,option,share". A header row is skipped if its share isn't a
+// number. Shares may be given as fractions or percentages; they are
+// normalized to sum to 1 per question.
+func LoadRakeTargets(filename string) ([]RakeTarget, error) {
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    cr := csv.NewReader(f)
+    cr.FieldsPerRecord = -1
+    records, err := cr.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("failed to read targets %s: %w", filename, err)
+    }
+
+    var targets []RakeTarget
+    index := make(map[string]int)
+    for i, rec := range records {
+        if len(rec) < 3 {
+            return nil, fmt.Errorf("targets %s line %d: expected question, option and share", filename, i+1)
+        }
+        share, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(rec[2]), "%"), 64)
+        if err != nil {
+            if i == 0 {
+                continue
+            }
+            return nil, fmt.Errorf("targets %s line %d: invalid share %q", filename, i+1, rec[2])
+        }
+        if share < 0 {
+            return nil, fmt.Errorf("targets %s line %d: negative share", filename, i+1)
+        }
+        key := strings.TrimSpace(rec[0])
+        j, ok := index[key]
+        if !ok {
+            j = len(targets)
+            index[key] = j
+            targets = append(targets, RakeTarget{Key: key, Shares: make(map[string]float64)})
+        }
+        targets[j].Shares[strings.TrimSpace(rec[1])] = share
+    }
+    for _, t := range targets {
+        sum := 0.0
+        for _, share := range t.Shares {
+            sum += share
+        }
+        if sum <= 0 {
+            return nil, fmt.Errorf("targets %s: shares of %q sum to 0", filename, t.Key)
+        }
+        for opt, share := range t.Shares {
+            t.Shares[opt] = share / sum
+        }
+    }
+    return targets, nil
+}
+
+// RakeOptions controls the iteration of Rake. Zero values select the
+// defaults.
+type RakeOptions struct {
+    MaxIterations int     // default 100
+    Tolerance     float64 // largest accepted share difference, default 1e-6
+}
+
+// RakeResult holds the weights computed by Rake and their diagnostics.
+type RakeResult struct {
+    Weights    []float64
+    Iterations int
+    Converged  bool
+    // MaxError is the largest difference between a weighted and a target
+    // share after the last iteration
+    MaxError float64
+    // DesignEffect is Kish's approximation n*sum(w²)/sum(w)²
+    DesignEffect float64
+    Min, Max     float64
+}
+
+// rakeDimension is a raking target resolved against the data: the target
+// share per option code and the code of each row, or -1 for rows without
+// an answer, which are left out of this dimension.
+type rakeDimension struct {
+    key    string
+    shares []float64
+    codes  []int
+}
+
+func (sd *SurveyData) rakeDimension(t RakeTarget) (*rakeDimension, error) {
+    entry, ok := sd.Schema.Get(t.Key)
+    if !ok {
+        return nil, fmt.Errorf("question %q not found", t.Key)
+    }
+    if entry.QType != SC {
+        return nil, fmt.Errorf("question %q is not single choice", t.Key)
+    }
+    col, ok := sd.Column(t.Key)
+    if !ok {
+        return nil, fmt.Errorf("question %q has no data", t.Key)
+    }
+    dim := &rakeDimension{key: t.Key, shares: make([]float64, len(entry.UsedOptions)), codes: make([]int, sd.n)}
+    for opt, share := range t.Shares {
+        code := -1
+        for i, used := range entry.UsedOptions {
+            if used == opt {
+                code = i
+                break
+            }
+        }
+        if code < 0 {
+            if share > 0 {
+                return nil, fmt.Errorf("option %q of %q has a target but no responses", opt, t.Key)
+            }
+            continue
+        }
+        dim.shares[code] = share
+    }
+    for row := range dim.codes {
+        dim.codes[row] = col.Code(row)
+        if code := dim.codes[row]; code >= 0 {
+            if _, ok := t.Shares[entry.UsedOptions[code]]; !ok {
+                return nil, fmt.Errorf("option %q of %q has no target", entry.UsedOptions[code], t.Key)
+            }
+        }
+    }
+    return dim, nil
+}
+
+// margins returns the weighted share of each option among the rows that
+// answered the question.
+func (dim *rakeDimension) margins(weights []float64) []float64 {
+    sums := make([]float64, len(dim.shares))
+    total := 0.0
+    for row, code := range dim.codes {
+        if code >= 0 {
+            sums[code] += weights[row]
+            total += weights[row]
+        }
+    }
+    if total > 0 {
+        for i := range sums {
+            sums[i] /= total
+        }
+    }
+    return sums
+}
+
+// Rake computes respondent weights by iterative proportional fitting, so
+// that the weighted shares of the target questions match the targets.
+// Existing weights serve as the starting point. The resulting weights are
+// scaled to a mean of 1. The data's weights aren't changed.
+func (sd *SurveyData) Rake(targets []RakeTarget, opts RakeOptions) (*RakeResult, error) {
+    if len(targets) == 0 {
+        return nil, fmt.Errorf("no raking targets")
+    }
+    if sd.n == 0 {
+        return nil, fmt.Errorf("no responses")
+    }
+    if opts.MaxIterations <= 0 {
+        opts.MaxIterations = 100
+    }
+    if opts.Tolerance <= 0 {
+        opts.Tolerance = 1e-6
+    }
+    dims := make([]*rakeDimension, len(targets))
+    for i, t := range targets {
+        dim, err := sd.rakeDimension(t)
+        if err != nil {
+            return nil, err
+        }
+        dims[i] = dim
+    }
+
+    weights := make([]float64, sd.n)
+    for row := range weights {
+        weights[row] = sd.Weight(row)
+    }
+    res := &RakeResult{Weights: weights}
+    for res.Iterations < opts.MaxIterations {
+        res.Iterations++
+        for _, dim := range dims {
+            margins := dim.margins(weights)
+            for row, code := range dim.codes {
+                if code >= 0 && margins[code] > 0 {
+                    weights[row] *= dim.shares[code] / margins[code]
+                }
+            }
+        }
+        res.MaxError = 0
+        for _, dim := range dims {
+            for code, m := range dim.margins(weights) {
+                res.MaxError = max(res.MaxError, math.Abs(m-dim.shares[code]))
+            }
+        }
+        if res.MaxError <= opts.Tolerance {
+            res.Converged = true
+            break
+        }
+    }
+
+    sum, sumSq := 0.0, 0.0
+    for _, w := range weights {
+        sum += w
+        sumSq += w * w
+    }
+    if sum <= 0 {
+        return nil, fmt.Errorf("all weights are 0")
+    }
+    scale := float64(sd.n) / sum
+    res.Min, res.Max = math.Inf(1), math.Inf(-1)
+    for row := range weights {
+        weights[row] *= scale
+        res.Min = min(res.Min, weights[row])
+        res.Max = max(res.Max, weights[row])
+    }
+    res.DesignEffect = float64(sd.n) * sumSq / (sum * sum)
+    return res, nil
+}
@@ -0,0 +1,96 @@
+package survey
+
+import (
+    "math"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestLoadRakeTargets(t *testing.T) {
+    filename := filepath.Join(t.TempDir(), "targets.csv")
+    content := "question,option,share\nCountry,DE,30%\nCountry,US,70%\nAge,young,1\nAge,old,3\n"
+    if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+    targets, err := LoadRakeTargets(filename)
+    if err != nil {
+        t.Fatalf("LoadRakeTargets failed: %v", err)
+    }
+    if len(targets) != 2 || targets[0].Key != "Country" || targets[1].Key != "Age" {
+        t.Fatalf("targets = %v", targets)
+    }
+    if got := targets[0].Shares["DE"]; math.Abs(got-0.3) > 1e-9 {
+        t.Errorf("DE share = %v, want 0.3", got)
+    }
+    if got := targets[1].Shares["old"]; got != 0.75 {
+        t.Errorf("old share = %v, want 0.75", got)
+    }
+}
+
+func TestSurveyData_Rake(t *testing.T) {
+    schema := Schema{
+        {Key: "Country", Text: "Country", QType: SC},
+        {Key: "Age", Text: "Age", QType: SC},
+    }
+    var responses []Response
+    add := func(country, age string, count int) {
+        for range count {
+            responses = append(responses, Response{"Country": {Val: country}, "Age": {Val: age}})
+        }
+    }
+    add("DE", "young", 30)
+    add("DE", "old", 20)
+    add("US", "young", 10)
+    add("US", "old", 40)
+    responses = append(responses, Response{"Country": {Val: "US"}, "Age": {Val: nil}})
+    sd := NewSurveyData(schema, responses)
+
+    targets := []RakeTarget{
+        {Key: "Country", Shares: map[string]float64{"DE": 0.3, "US": 0.7}},
+        {Key: "Age", Shares: map[string]float64{"young": 0.5, "old": 0.5}},
+    }
+    res, err := sd.Rake(targets, RakeOptions{})
+    if err != nil {
+        t.Fatalf("Rake failed: %v", err)
+    }
+    if !res.Converged {
+        t.Fatalf("Rake did not converge after %d iterations (max error %v)", res.Iterations, res.MaxError)
+    }
+    if err := sd.SetWeights(res.Weights, "raking"); err != nil {
+        t.Fatal(err)
+    }
+    for _, target := range targets {
+        dist, err := sd.Distribution(target.Key, nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for code, opt := range dist.Entry.UsedOptions {
+            share := dist.Weighted[code] / dist.WeightedRespondents
+            if math.Abs(share-target.Shares[opt]) > 1e-5 {
+                t.Errorf("%s=%s weighted share = %v, want %v", target.Key, opt, share, target.Shares[opt])
+            }
+        }
+    }
+    if sum := sd.WeightedCount(sd.Rows()); math.Abs(sum-float64(sd.Len())) > 1e-9 {
+        t.Errorf("sum of weights = %v, want %d", sum, sd.Len())
+    }
+    if res.DesignEffect <= 1 || res.Min <= 0 || res.Max <= res.Min {
+        t.Errorf("diagnostics: deff %v, min %v, max %v", res.DesignEffect, res.Min, res.Max)
+    }
+
+    if err := sd.SetNumericColumn("RakeWeight", "Raking weight", res.Weights); err != nil {
+        t.Fatalf("SetNumericColumn failed: %v", err)
+    }
+    if err := sd.UseWeightColumn("RakeWeight"); err != nil {
+        t.Fatalf("UseWeightColumn failed: %v", err)
+    }
+    if sd.Weight(0) != res.Weights[0] {
+        t.Errorf("Weight(0) = %v, want %v", sd.Weight(0), res.Weights[0])
+    }
+
+    missing := []RakeTarget{{Key: "Country", Shares: map[string]float64{"DE": 1}}}
+    if _, err := sd.Rake(missing, RakeOptions{}); err == nil {
+        t.Error("expected error for an option without a target")
+    }
+}
@@ -2,130 +2,362 @@ package survey
 
 import (
     "compress/gzip"
+    "crypto/sha256"
+    "encoding/hex"
     "encoding/json"
+    "errors"
     "fmt"
     "io"
     "os"
     "path/filepath"
-
-    "github.com/xuri/excelize/v2"
+    "slices"
 )
 
+// ReadOptions configures how survey data is read from its source.
+type ReadOptions struct {
+    // Progress, if set, is called while responses are read with the number
+    // of rows processed so far and the estimated total (0 if unknown).
+    Progress func(done, total int)
+    // Format names the input format, see SourceFormats. If empty, the
+    // format is picked by the file extension.
+    Format string
+    // RulesFile names a normalization rules file applied to the option
+    // values while reading, see LoadNormalizationRules. The file is part
+    // of the cache fingerprint.
+    RulesFile string
+}
+
+// inputFiles returns all files the data read with opts depends on, given
+// the files of its source.
+func (opts ReadOptions) inputFiles(files []string) []string {
+    if opts.RulesFile != "" {
+        files = append(files, opts.RulesFile)
+    }
+    return files
+}
+
+// progressInterval is the number of rows between two progress callbacks.
+const progressInterval = 1000
+
 func ReadSurveyData(filename string) (*SurveyData, error) {
-    f, err := excelize.OpenFile(filename)
+    return ReadSurveyDataWithOptions(filename, ReadOptions{})
+}
+
+// ReadSurveyDataWithOptions reads a dataset in any supported format, see
+// OpenSource. The raw data is streamed row by row and never held in memory
+// as a whole.
+func ReadSurveyDataWithOptions(filename string, opts ReadOptions) (*SurveyData, error) {
+    src, err := OpenSource(filename, opts.Format)
+    if err != nil {
+        return nil, err
+    }
+    defer src.Close()
+    sources, err := describeSources(opts.inputFiles(src.Files()))
     if err != nil {
         return nil, fmt.Errorf("failed to open file: %w", err)
     }
-    defer f.Close()
+    var rules *NormalizationRules
+    if opts.RulesFile != "" {
+        if rules, err = LoadNormalizationRules(opts.RulesFile); err != nil {
+            return nil, err
+        }
+    }
 
     // Read schema
-    schema := make(Schema, 0)
-    rows, err := f.GetRows("schema")
+    ds := &diagnostics{}
+    schema, err := src.Schema()
     if err != nil {
-        return nil, fmt.Errorf("failed to read schema sheet: %w", err)
+        return nil, err
     }
-    for i, row := range rows {
-        if i == 0 {
-            continue // skip header
-        }
-        if len(row) < 3 {
-            continue
-        }
-        key := row[0]
-        text := row[1]
-        qtype := QuestionType(row[2])
-        schema.add(key, text, qtype)
+    schema = validSchema(schema, ds)
+    linkMatrices(schema, ds)
+    for _, entry := range schema {
+        entry.resetOptions()
     }
 
     // Read raw data
-    rawRows, err := f.GetRows("raw data")
+    rawRows, err := src.Rows()
     if err != nil {
-        return nil, fmt.Errorf("failed to read \"raw data\" sheet: %w", err)
+        return nil, err
     }
-    if len(rawRows) < 1 {
-        return nil, fmt.Errorf("\"raw data\" sheet is empty")
+    defer rawRows.Close()
+    if !rawRows.Next() {
+        if err := rawRows.Error(); err != nil {
+            return nil, fmt.Errorf("failed to read raw data: %w", err)
+        }
+        return nil, fmt.Errorf("raw data is empty")
     }
-    header := rawRows[0]
-    var responses []Response
-    for _, row := range rawRows[1:] {
-        resp := make(Response)
+    header, err := rawRows.Columns()
+    if err != nil {
+        return nil, fmt.Errorf("failed to read raw data header: %w", err)
+    }
+    // The reader may reuse its buffer for the next row
+    header = slices.Clone(header)
+    // Resolve the schema position of each header column once
+    positions := make([]int, len(header))
+    inHeader := make([]bool, len(schema))
+    for i, key := range header {
+        positions[i] = -1
+        j := slices.IndexFunc(schema, func(e *SchemaEntry) bool { return e.Key == key })
+        switch {
+        case j < 0:
+            ds.add(SeverityWarning, DiagUnknownColumn, key, 0,
+                "data column %q is not in the schema and is ignored", key)
+        case inHeader[j]:
+            ds.add(SeverityError, DiagDuplicateColumn, key, 0,
+                "data has more than one column %q, only the first one is read", key)
+        default:
+            positions[i] = j
+            inHeader[j] = true
+        }
+    }
+    for j, entry := range schema {
+        if !inHeader[j] && entry.QType != MATRIX {
+            ds.add(SeverityWarning, DiagMissingColumn, entry.Key, 0,
+                "question %q has no column in the data, all its answers are n/a", entry.Key)
+        }
+    }
+    normalizers := make([]func(string) string, len(schema))
+    for i, entry := range schema {
+        normalizers[i] = rules.normalizer(entry.Key)
+    }
+    b := newDataBuilder(schema)
+    for rawRows.Next() {
+        row, err := rawRows.Columns()
+        if err != nil {
+            return nil, fmt.Errorf("failed to read raw data row %d: %w", b.n+2, err)
+        }
+        dataRow := b.n + 1
+        if len(row) < len(header) {
+            ds.add(SeverityInfo, DiagShortRow, "", dataRow,
+                "rows have fewer cells than the header, the missing cells are read as n/a")
+        } else if len(row) > len(header) {
+            ds.add(SeverityWarning, DiagLongRow, "", dataRow,
+                "rows have more cells than the header, the extra cells are ignored")
+        }
+        vals := make([]ResponseValue, len(schema))
         for i, cell := range row {
             if i >= len(header) {
                 break
             }
-            key := header[i]
-            entry, ok := schema.Get(key)
-            if !ok {
+            pos := positions[i]
+            if pos < 0 {
                 continue
             }
-            resp[key] = entry.ParseValue(cell)
+            entry := schema[pos]
+            vals[pos] = entry.parseValue(cell, normalizers[pos])
+            if vals[pos].Val == nil && entry.QType == NUM && cell != "" && cell != "NA" {
+                ds.add(SeverityWarning, DiagUnparsedValue, entry.Key, dataRow,
+                    "numeric question %q has values that are not numbers, they are read as n/a", entry.Key)
+            }
+        }
+        b.addRow(vals)
+        if opts.Progress != nil && b.n%progressInterval == 0 {
+            opts.Progress(b.n, max(rawRows.Estimate(), b.n))
         }
-        responses = append(responses, resp)
+    }
+    if err := rawRows.Error(); err != nil {
+        return nil, fmt.Errorf("failed to read raw data: %w", err)
+    }
+    if opts.Progress != nil {
+        opts.Progress(b.n, b.n)
     }
 
-    return &SurveyData{
-        Schema:    schema,
-        Responses: responses,
-    }, nil
+    sd := b.build()
+    sd.Sources = sources
+    sd.LoadedFrom = filename
+    sd.RulesFile = opts.RulesFile
+    sortDiagnostics(ds.list)
+    sd.Diagnostics = ds.list
+    return sd, nil
 }
 
+// LoadSurveyData reads survey data written by WriteJSON. Responses are
+// decoded one at a time and appended to the column store directly.
 func LoadSurveyData(r io.Reader) (*SurveyData, error) {
-    var sd SurveyData
     dec := json.NewDecoder(r)
-    if err := dec.Decode(&sd); err != nil {
+    if err := expectDelim(dec, '{'); err != nil {
         return nil, err
     }
-    return &sd, nil
+    var header *CacheHeader
+    var schema Schema
+    var b *dataBuilder
+    for dec.More() {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        switch tok {
+        case "Header":
+            if err := dec.Decode(&header); err != nil {
+                return nil, fmt.Errorf("header: %w", err)
+            }
+        case "Schema":
+            if err := dec.Decode(&schema); err != nil {
+                return nil, fmt.Errorf("schema: %w", err)
+            }
+        case "Responses":
+            if b != nil {
+                return nil, errors.New("duplicate responses")
+            }
+            if schema == nil {
+                return nil, errors.New("responses must follow the schema")
+            }
+            b = newDataBuilder(schema)
+            if err := decodeResponses(dec, b); err != nil {
+                return nil, fmt.Errorf("responses: %w", err)
+            }
+        default:
+            var skip json.RawMessage
+            if err := dec.Decode(&skip); err != nil {
+                return nil, err
+            }
+        }
+    }
+    if err := expectDelim(dec, '}'); err != nil {
+        return nil, err
+    }
+    if b == nil {
+        b = newDataBuilder(schema)
+    }
+    sd := b.build()
+    sd.applyCacheHeader(header)
+    return sd, nil
+}
+
+func decodeResponses(dec *json.Decoder, b *dataBuilder) error {
+    tok, err := dec.Token()
+    if err != nil {
+        return err
+    }
+    if tok == nil {
+        return nil
+    }
+    if d, ok := tok.(json.Delim); !ok || d != '[' {
+        return fmt.Errorf("expected array, got %v", tok)
+    }
+    for dec.More() {
+        var resp Response
+        if err := dec.Decode(&resp); err != nil {
+            return err
+        }
+        b.add(resp)
+    }
+    return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+    tok, err := dec.Token()
+    if err != nil {
+        return err
+    }
+    if d, ok := tok.(json.Delim); !ok || d != want {
+        return fmt.Errorf("expected %v, got %v", want, tok)
+    }
+    return nil
 }
 
+// LoadSurveyDataFromFile reads a cache file written by WriteToFile. Files
+// ending in .bin use the binary format, all others JSON, optionally gzipped.
 func LoadSurveyDataFromFile(filename string) (*SurveyData, error) {
+    if isBinaryFile(filename) {
+        buf, err := os.ReadFile(filename)
+        if err != nil {
+            return nil, err
+        }
+        sd, err := LoadSurveyDataBinary(buf)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", filename, err)
+        }
+        sd.LoadedFrom = filename
+        return sd, nil
+    }
     f, err := os.Open(filename)
     if err != nil {
         return nil, err
     }
     defer f.Close()
+    var r io.Reader = f
     if len(filename) > 3 && filename[len(filename)-3:] == ".gz" {
         gr, err := gzip.NewReader(f)
         if err != nil {
             return nil, err
         }
         defer gr.Close()
-        return LoadSurveyData(gr)
+        r = gr
+    }
+    sd, err := LoadSurveyData(r)
+    if err != nil {
+        return nil, err
     }
-    return LoadSurveyData(f)
+    sd.LoadedFrom = filename
+    return sd, nil
 }
 
-func createCacheFilename(xlsxFile string) string {
-    // Generate cache file name based on Excel file name, strip extension
-    base := filepath.Base(xlsxFile)
-    name := base
-    if ext := filepath.Ext(base); ext != "" {
-        name = base[:len(base)-len(ext)]
+// createCacheFilename returns the name of the cache file for a source file
+// in the current directory. Besides the source's name it contains a short
+// hash of its absolute path, so that sources with the same name in
+// different directories, like the results of several survey years, don't
+// share a cache.
+func createCacheFilename(filename string) string {
+    base := filepath.Base(filename)
+    name := base[:len(base)-len(filepath.Ext(base))]
+    path, err := filepath.Abs(filename)
+    if err != nil {
+        path = filename
     }
-    return "_" + name + ".cache.json.gz"
+    sum := sha256.Sum256([]byte(path))
+    return "_" + name + "." + hex.EncodeToString(sum[:4]) + ".cache" + binaryExt
+}
+
+// ReadSurveyDataCached loads survey data from the cache file belonging to
+// filename. The cache is rebuilt from the source if it is missing, was
+// written by a different cache format version or doesn't match the size,
+// modification time and content hash of every source file.
+func ReadSurveyDataCached(filename string) (*SurveyData, error) {
+    return ReadSurveyDataCachedWithOptions(filename, ReadOptions{})
 }
 
-func ReadSurveyDataCached(xlsxFile string) (*SurveyData, error) {
-    jsonFile := createCacheFilename(xlsxFile)
+// ReadSurveyDataCachedWithOptions is ReadSurveyDataCached with options for
+// the case the cache has to be rebuilt.
+func ReadSurveyDataCachedWithOptions(filename string, opts ReadOptions) (*SurveyData, error) {
+    cacheFile := createCacheFilename(filename)
+
+    // The source is only opened if the cache has to be rebuilt
+    var sources []SourceInfo
+    files, err := SourceFiles(filename, opts.Format)
+    if err == nil {
+        sources, err = describeSources(opts.inputFiles(files))
+    }
+    if err != nil {
+        // Without the source files the cache is all we have, as long as
+        // it was written for them
+        header, headerErr := readCacheHeader(cacheFile)
+        if headerErr != nil {
+            return nil, fmt.Errorf("could not find %s or %s: %w", cacheFile, filename, err)
+        }
+        if len(header.Sources) == 0 || !samePath(header.Sources[0].Path, filename) {
+            return nil, fmt.Errorf("could not read %s and the cache %s was written for other sources: %w", filename, cacheFile, err)
+        }
+        return LoadSurveyDataFromFile(cacheFile)
+    }
 
-    // Try to load from JSON first
-    if _, err := os.Stat(jsonFile); err == nil {
-        data, err := LoadSurveyDataFromFile(jsonFile)
+    // Try to load from the cache first
+    if header, err := readCacheHeader(cacheFile); err == nil && header.mismatch(sources) == "" {
+        data, err := LoadSurveyDataFromFile(cacheFile)
         if err == nil {
+            data.RulesFile = opts.RulesFile
             return data, nil
         }
-        // If JSON exists but is invalid, fall back to XLSX
-    }
-    // Fallback: load from XLSX and write JSON
-    if _, err := os.Stat(xlsxFile); err != nil {
-        return nil, fmt.Errorf("could not find %s or %s", jsonFile, xlsxFile)
+        // If the cache exists but is invalid, fall back to the source
     }
-    data, err := ReadSurveyData(xlsxFile)
+    // Fallback: load from the source and write the cache
+    data, err := ReadSurveyDataWithOptions(filename, opts)
     if err != nil {
-        return nil, fmt.Errorf("failed to read %s: %w", xlsxFile, err)
+        return nil, fmt.Errorf("failed to read %s: %w", filename, err)
     }
-    if err := data.WriteJSONToFile(jsonFile); err != nil {
-        return nil, fmt.Errorf("failed to write %s: %w", jsonFile, err)
+    if err := data.WriteToFile(cacheFile); err != nil {
+        return nil, fmt.Errorf("failed to write %s: %w", cacheFile, err)
     }
     return data, nil
 }
@@ -5,7 +5,11 @@ import (
     "compress/gzip"
     "os"
     "path/filepath"
+    "slices"
     "testing"
+    "time"
+
+    "github.com/xuri/excelize/v2"
 )
 
 func TestSurveyData_ReadSurveyData(t *testing.T) {
@@ -19,7 +23,7 @@ func TestSurveyData_ReadSurveyData(t *testing.T) {
     if len(data.Schema) == 0 {
         t.Error("schema should not be empty")
     }
-    if len(data.Responses) == 0 {
+    if data.Len() == 0 {
         t.Error("responses should not be empty")
     }
 
@@ -31,7 +35,7 @@ func TestSurveyData_ReadSurveyData(t *testing.T) {
     }
 
     // Check values for first response (if available)
-    resp := data.Responses[0]
+    resp := data.Response(0)
     for _, entry := range data.Schema {
         val := resp[entry.Key]
         switch entry.QType {
@@ -57,7 +61,7 @@ func TestSurveyData_ReadSurveyData(t *testing.T) {
     }
 
     // Check NA and empty handling
-    for _, resp := range data.Responses {
+    for _, resp := range data.Responses(data.Rows()) {
         for _, entry := range data.Schema {
             val := resp[entry.Key]
             if !val.Present() {
@@ -74,13 +78,13 @@ func TestSurveyData_ReadSurveyData(t *testing.T) {
 }
 
 func TestSurveyData_LoadJSON(t *testing.T) {
-    sd := &SurveyData{
-        Schema: Schema{
+    sd := NewSurveyData(
+        Schema{
             {Key: "Q1", Text: "Question 1", QType: SC},
             {Key: "Q2", Text: "Question 2", QType: MC},
             {Key: "Q3", Text: "Question 3", QType: TE},
         },
-        Responses: []Response{
+        []Response{
             {
                 "Q1": {Val: "foo"},
                 "Q2": {Val: []string{"a", "b"}},
@@ -92,7 +96,7 @@ func TestSurveyData_LoadJSON(t *testing.T) {
                 "Q3": {Val: nil},
             },
         },
-    }
+    )
 
     var buf bytes.Buffer
     err := sd.WriteJSON(&buf)
@@ -108,8 +112,8 @@ func TestSurveyData_LoadJSON(t *testing.T) {
     if len(loaded.Schema) != len(sd.Schema) {
         t.Errorf("Loaded schema length mismatch: got %d, want %d", len(loaded.Schema), len(sd.Schema))
     }
-    if len(loaded.Responses) != len(sd.Responses) {
-        t.Errorf("Loaded responses length mismatch: got %d, want %d", len(loaded.Responses), len(sd.Responses))
+    if loaded.Len() != sd.Len() {
+        t.Errorf("Loaded responses length mismatch: got %d, want %d", loaded.Len(), sd.Len())
     }
 
     // --- Gzipped JSON roundtrip ---
@@ -132,20 +136,20 @@ func TestSurveyData_LoadJSON(t *testing.T) {
     if len(loadedGz.Schema) != len(sd.Schema) {
         t.Errorf("Loaded (gzip) schema length mismatch: got %d, want %d", len(loadedGz.Schema), len(sd.Schema))
     }
-    if len(loadedGz.Responses) != len(sd.Responses) {
-        t.Errorf("Loaded (gzip) responses length mismatch: got %d, want %d", len(loadedGz.Responses), len(sd.Responses))
+    if loadedGz.Len() != sd.Len() {
+        t.Errorf("Loaded (gzip) responses length mismatch: got %d, want %d", loadedGz.Len(), sd.Len())
     }
 }
 
 func TestSurveyData_WriteJSONToFile_Gzipped(t *testing.T) {
-    sd := &SurveyData{
-        Schema: Schema{
+    sd := NewSurveyData(
+        Schema{
             {Key: "Q1", Text: "Question 1", QType: SC},
         },
-        Responses: []Response{
+        []Response{
             {"Q1": {Val: "foo"}},
         },
-    }
+    )
     gzFile := "test.cache.json.gz"
     defer os.Remove(gzFile)
 
@@ -163,7 +167,232 @@ func TestSurveyData_WriteJSONToFile_Gzipped(t *testing.T) {
     if len(loaded.Schema) != len(sd.Schema) {
         t.Errorf("Loaded (gz) schema length mismatch: got %d, want %d", len(loaded.Schema), len(sd.Schema))
     }
-    if len(loaded.Responses) != len(sd.Responses) {
-        t.Errorf("Loaded (gz) responses length mismatch: got %d, want %d", len(loaded.Responses), len(sd.Responses))
+    if loaded.Len() != sd.Len() {
+        t.Errorf("Loaded (gz) responses length mismatch: got %d, want %d", loaded.Len(), sd.Len())
+    }
+}
+
+func TestReadSurveyDataCached_Invalidation(t *testing.T) {
+    src, err := os.ReadFile(filepath.Join(".", "so_test.xlsx"))
+    if err != nil {
+        t.Fatalf("failed to read test data: %v", err)
+    }
+    t.Chdir(t.TempDir())
+    if err := os.WriteFile("data.xlsx", src, 0o644); err != nil {
+        t.Fatal(err)
+    }
+    cacheFile := createCacheFilename("data.xlsx")
+
+    data, err := ReadSurveyDataCached("data.xlsx")
+    if err != nil {
+        t.Fatalf("first load failed: %v", err)
+    }
+    if data.LoadedFrom != "data.xlsx" {
+        t.Errorf("first load from %q, want data.xlsx", data.LoadedFrom)
+    }
+    if len(data.Sources) != 1 || data.Sources[0].SHA256 == "" {
+        t.Fatalf("source info missing after reading xlsx: %+v", data.Sources)
+    }
+
+    data, err = ReadSurveyDataCached("data.xlsx")
+    if err != nil {
+        t.Fatalf("second load failed: %v", err)
+    }
+    if data.LoadedFrom != cacheFile {
+        t.Errorf("second load from %q, want %q", data.LoadedFrom, cacheFile)
+    }
+    if len(data.Sources) != 1 || data.Sources[0].Path != "data.xlsx" {
+        t.Errorf("cached source info = %+v, want path data.xlsx", data.Sources)
+    }
+
+    // Touching the source invalidates the cache
+    later := data.Sources[0].ModTime.Add(time.Hour)
+    if err := os.Chtimes("data.xlsx", later, later); err != nil {
+        t.Fatal(err)
+    }
+    data, err = ReadSurveyDataCached("data.xlsx")
+    if err != nil {
+        t.Fatalf("load after touch failed: %v", err)
+    }
+    if data.LoadedFrom != "data.xlsx" {
+        t.Errorf("load after touch from %q, want data.xlsx", data.LoadedFrom)
+    }
+
+    // A cache recording a different content hash is rebuilt as well
+    data.Sources[0].SHA256 = "stale"
+    if err := data.WriteJSONToFile(cacheFile); err != nil {
+        t.Fatal(err)
+    }
+    data, err = ReadSurveyDataCached("data.xlsx")
+    if err != nil {
+        t.Fatalf("load after content change failed: %v", err)
+    }
+    if data.LoadedFrom != "data.xlsx" {
+        t.Errorf("load with stale hash from %q, want data.xlsx", data.LoadedFrom)
+    }
+}
+
+func TestReadSurveyDataCached_SameNameInOtherDirectory(t *testing.T) {
+    src, err := os.ReadFile(filepath.Join(".", "so_test.xlsx"))
+    if err != nil {
+        t.Fatalf("failed to read test data: %v", err)
+    }
+    t.Chdir(t.TempDir())
+    for _, dir := range []string{"2023", "2024"} {
+        if err := os.Mkdir(dir, 0o755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(filepath.Join(dir, "data.xlsx"), src, 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    older, newer := filepath.Join("2023", "data.xlsx"), filepath.Join("2024", "data.xlsx")
+    if createCacheFilename(older) == createCacheFilename(newer) {
+        t.Fatalf("%s and %s share the cache %s", older, newer, createCacheFilename(older))
+    }
+    for _, filename := range []string{older, newer} {
+        if _, err := ReadSurveyDataCached(filename); err != nil {
+            t.Fatalf("first load of %s failed: %v", filename, err)
+        }
+    }
+    data, err := ReadSurveyDataCached(older)
+    if err != nil {
+        t.Fatalf("second load failed: %v", err)
+    }
+    if data.LoadedFrom != createCacheFilename(older) {
+        t.Errorf("second load of %s from %q, want its cache", older, data.LoadedFrom)
+    }
+
+    // Without its source, a cache written for another file isn't used
+    if err := os.Rename(createCacheFilename(newer), createCacheFilename(older)); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Remove(older); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := ReadSurveyDataCached(older); err == nil {
+        t.Errorf("loading %s without its source used the cache of %s", older, newer)
+    }
+}
+
+func TestCacheHeader_Mismatch(t *testing.T) {
+    src := SourceInfo{Path: "a.xlsx", Size: 10, ModTime: time.Unix(100, 0).UTC(), SHA256: "abc"}
+    tests := []struct {
+        name   string
+        header CacheHeader
+        ok     bool
+    }{
+        {"match", CacheHeader{Version: cacheFormatVersion, Sources: []SourceInfo{src}}, true},
+        {"version", CacheHeader{Version: cacheFormatVersion + 1, Sources: []SourceInfo{src}}, false},
+        {"size", CacheHeader{Version: cacheFormatVersion, Sources: []SourceInfo{{Path: "a.xlsx", Size: 11, ModTime: src.ModTime, SHA256: "abc"}}}, false},
+        {"mtime", CacheHeader{Version: cacheFormatVersion, Sources: []SourceInfo{{Path: "a.xlsx", Size: 10, ModTime: time.Unix(101, 0), SHA256: "abc"}}}, false},
+        {"hash", CacheHeader{Version: cacheFormatVersion, Sources: []SourceInfo{{Path: "a.xlsx", Size: 10, ModTime: src.ModTime, SHA256: "def"}}}, false},
+        {"path", CacheHeader{Version: cacheFormatVersion, Sources: []SourceInfo{{Path: "b/a.xlsx", Size: 10, ModTime: src.ModTime, SHA256: "abc"}}}, false},
+        {"count", CacheHeader{Version: cacheFormatVersion, Sources: []SourceInfo{src, src}}, false},
+    }
+    for _, tt := range tests {
+        srcs := []SourceInfo{src}
+        if got := tt.header.mismatch(srcs) == ""; got != tt.ok {
+            t.Errorf("%s: mismatch() = %q", tt.name, tt.header.mismatch(srcs))
+        }
+    }
+}
+
+func TestReadSurveyDataWithOptions_Progress(t *testing.T) {
+    type call struct{ done, total int }
+    var calls []call
+    data, err := ReadSurveyDataWithOptions(filepath.Join(".", "so_test.xlsx"), ReadOptions{
+        Progress: func(done, total int) {
+            calls = append(calls, call{done, total})
+        },
+    })
+    if err != nil {
+        t.Fatalf("failed to read test data: %v", err)
+    }
+    if len(calls) == 0 {
+        t.Fatal("progress callback was never called")
+    }
+    last := calls[len(calls)-1]
+    if last.done != data.Len() || last.total != data.Len() {
+        t.Errorf("last progress = %+v, want done and total %d", last, data.Len())
+    }
+    for _, c := range calls {
+        if c.done > c.total {
+            t.Errorf("progress done %d exceeds total %d", c.done, c.total)
+        }
+    }
+}
+
+func TestEstimateDataRows(t *testing.T) {
+    f, err := excelize.OpenFile(filepath.Join(".", "so_test.xlsx"))
+    if err != nil {
+        t.Fatalf("failed to open test data: %v", err)
+    }
+    defer f.Close()
+    if got := estimateDataRows(f, "raw data"); got != 10 {
+        t.Errorf("estimateDataRows() = %d, want 10", got)
+    }
+}
+
+func TestReadSurveyData_DeclaredOptions(t *testing.T) {
+    dir := t.TempDir()
+    dataFile := filepath.Join(dir, "data.csv")
+    writeCSV(t, filepath.Join(dir, "data_schema.csv"), [][]string{
+        {"column", "question_text", "type", "options", "ordinal"},
+        {"Age", "Age", "SC", "Under 18;18-24;25-34;35+", "yes"},
+        {"Lang", "Languages", "MC", "Go;Rust;Python", ""},
+    })
+    writeCSV(t, dataFile, [][]string{
+        {"Age", "Lang"},
+        {"25-34", "Go;Python"},
+        {"18-24", "Pyton;Rust"},
+        {"25-34", "Go;Pyton"},
+        {"unknown", "Go"},
+    })
+    sd, err := ReadSurveyData(dataFile)
+    if err != nil {
+        t.Fatalf("ReadSurveyData failed: %v", err)
+    }
+
+    age, _ := sd.Schema.Get("Age")
+    if want := []string{"Under 18", "18-24", "25-34", "35+", "unknown"}; !slices.Equal(age.UsedOptions, want) || !age.Ordinal {
+        t.Errorf("Age options = %q (ordinal %v), want %q", age.UsedOptions, age.Ordinal, want)
+    }
+    dist, err := sd.Distribution("Age", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Equal(dist.Counts, []int{0, 1, 2, 0, 1}) {
+        t.Errorf("Age counts = %v", dist.Counts)
+    }
+    if code, ok := dist.Median(); !ok || age.UsedOptions[code] != "25-34" {
+        t.Errorf("Age median = %d, %v; want 25-34", code, ok)
+    }
+    langDist, _ := sd.Distribution("Lang", nil)
+    if _, ok := langDist.Median(); ok {
+        t.Error("MC question should have no median")
+    }
+
+    diags := sd.Validate()
+    for _, key := range []string{"Age", "Lang"} {
+        if _, ok := findDiagnostic(diags, DiagUndeclared, key); !ok {
+            t.Errorf("missing %s diagnostic for %q", DiagUndeclared, key)
+        }
+    }
+
+    // Undeclared options are merged into declared ones, even if less frequent
+    suggestions, err := sd.SuggestMerges("Lang", 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(suggestions) != 1 || suggestions[0].From != "Pyton" || suggestions[0].To != "Python" {
+        t.Errorf("SuggestMerges = %+v", suggestions)
+    }
+    if err := sd.MergeOption("Lang", "Pyton", "Python"); err != nil {
+        t.Fatal(err)
+    }
+    lang, _ := sd.Schema.Get("Lang")
+    if !slices.Equal(lang.UsedOptions, []string{"Go", "Rust", "Python"}) {
+        t.Errorf("Lang options after merge = %q", lang.UsedOptions)
     }
 }
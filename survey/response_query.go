@@ -3,48 +3,285 @@ package survey
 import (
     "errors"
     "fmt"
+    "math"
     "regexp"
+    "slices"
     "strconv"
     "strings"
+    "unicode"
+    "unicode/utf8"
 )
 
 type ResponseQuery struct {
     Keys  []string
     Range RangeSelector
+    // IDs restricts the query to the responses with the given respondent
+    // IDs, see SurveyData.ID. Nil selects all responses.
+    IDs []IDRange
+    // Where restricts the query to the responses matching a filter. Nil
+    // selects all responses.
+    Where *Filter
+    // Sample draws a random sample of the responses left after the
+    // filter. Nil keeps all of them.
+    Sample *Sample
+    // Sort orders the selected responses before the range is applied. Nil
+    // keeps the order of the data.
+    Sort []SortKey
+
+    // foldKeys makes patterns match question keys ignoring case, see
+    // QueryOptions.FoldKeyCase.
+    foldKeys bool
+}
+
+// Select applies the query to rows of sd: rows whose respondent ID isn't
+// selected or that don't match the filter are dropped, a sample is drawn
+// from the remaining rows, which is sorted, then the range is applied. It
+// fails if the filter, the sample or the sort keys don't fit the schema
+// of sd.
+func (rq *ResponseQuery) Select(sd *SurveyData, rows []int) ([]int, error) {
+    if rq.IDs != nil {
+        matched := make([]int, 0)
+        for _, row := range rows {
+            id := sd.ID(row)
+            for _, r := range rq.IDs {
+                if r.contains(id) {
+                    matched = append(matched, row)
+                    break
+                }
+            }
+        }
+        rows = matched
+    }
+    if rq.Where != nil {
+        match, err := rq.Where.compile(sd)
+        if err != nil {
+            return nil, fmt.Errorf("where: %w", err)
+        }
+        matched := make([]int, 0)
+        for _, row := range rows {
+            if match(row) {
+                matched = append(matched, row)
+            }
+        }
+        rows = matched
+    }
+    if rq.Sample != nil {
+        sampled, err := rq.Sample.draw(sd, rows)
+        if err != nil {
+            return nil, fmt.Errorf("sample: %w", err)
+        }
+        rows = sampled
+    }
+    if rq.Sort != nil {
+        sorted, err := sortRows(sd, rq.Sort, rows)
+        if err != nil {
+            return nil, fmt.Errorf("sort: %w", err)
+        }
+        rows = sorted
+    }
+    return rq.LimitRows(rows), nil
+}
+
+// String returns the query in canonical form, which ParseResponseQuery
+// parses back into the same query: sections in the order keys, ids, where,
+// sample, sort, range, separated by "; ", with sections that select
+// everything left out and keys and values quoted where needed. The query
+// that selects everything is the empty string.
+func (rq *ResponseQuery) String() string {
+    var sections []string
+    if len(rq.Keys) > 0 {
+        keys := make([]string, len(rq.Keys))
+        for i, key := range rq.Keys {
+            keys[i] = quoteListItem(key)
+        }
+        sections = append(sections, "keys: "+strings.Join(keys, ", "))
+    }
+    if len(rq.IDs) > 0 {
+        ids := make([]string, len(rq.IDs))
+        for i, r := range rq.IDs {
+            ids[i] = quoteID(r.From)
+            if r.To != r.From {
+                ids[i] += ".." + quoteID(r.To)
+            }
+        }
+        sections = append(sections, "ids: "+strings.Join(ids, ", "))
+    }
+    if rq.Where != nil {
+        sections = append(sections, "where: "+rq.Where.String())
+    }
+    if rq.Sample != nil {
+        sections = append(sections, "sample: "+rq.Sample.String())
+    }
+    if len(rq.Sort) > 0 {
+        keys := make([]string, len(rq.Sort))
+        for i, key := range rq.Sort {
+            keys[i] = key.String()
+        }
+        sections = append(sections, "sort: "+strings.Join(keys, ", "))
+    }
+    if !rq.Range.isAll() {
+        sections = append(sections, "range: "+rq.Range.String())
+    }
+    return strings.Join(sections, "; ")
+}
+
+// quoteListItem quotes an item of the keys section if it would not be
+// read back as it is. A block or exclusion prefix stays in front of the
+// quotes.
+func quoteListItem(item string) string {
+    if item != "" && strings.TrimSpace(item) == item && !strings.ContainsAny(item, ",;'\"\n") {
+        return item
+    }
+    prefix := ""
+    if strings.HasPrefix(item, ExcludePrefix) {
+        prefix, item = ExcludePrefix, item[len(ExcludePrefix):]
+    }
+    if strings.HasPrefix(item, BlockPrefix) {
+        prefix, item = prefix+BlockPrefix, item[len(BlockPrefix):]
+    }
+    return prefix + `"` + strings.ReplaceAll(item, `"`, `""`) + `"`
+}
+
+// quoteID quotes an ID or range end of the ids section if it would not be
+// read back as it is.
+func quoteID(id string) string {
+    if id != "" && strings.TrimSpace(id) == id && !strings.ContainsAny(id, ",;'\"\n") && !strings.Contains(id, "..") {
+        return id
+    }
+    return `"` + strings.ReplaceAll(id, `"`, `""`) + `"`
+}
+
+// BlockPrefix marks a key of a ResponseQuery that selects all questions
+// of a block, e.g. @AI or @"Basic information".
+const BlockPrefix = "@"
+
+// ResolveKeys returns the question keys selected by the query, with block
+// references, patterns and type selectors expanded against the schema and
+// matrix questions replaced by the keys of their rows, see TypePrefix.
+// Keys selected more than once are returned once. Excluded keys are left
+// out; if the query only excludes keys, they are left out of all
+// questions. It fails if a pattern matches no question or nothing is left.
+func (rq *ResponseQuery) ResolveKeys(schema Schema) ([]string, error) {
+    var out, excluded []string
+    included := false
+    for _, sel := range rq.Keys {
+        if rest, ok := strings.CutPrefix(sel, ExcludePrefix); ok && rest != "" {
+            if _, exists := schema.Get(sel); !exists {
+                keys, _, err := selectKeys(schema, rest, rq.foldKeys)
+                if err != nil {
+                    return nil, err
+                }
+                excluded = append(excluded, keys...)
+                continue
+            }
+        }
+        included = true
+        keys, isPattern, err := selectKeys(schema, sel, rq.foldKeys)
+        if err != nil {
+            return nil, err
+        }
+        if isPattern && len(keys) == 0 {
+            return nil, fmt.Errorf("%q matches no questions", sel)
+        }
+        for _, key := range keys {
+            if !slices.Contains(out, key) {
+                out = append(out, key)
+            }
+        }
+    }
+    if excluded == nil {
+        return out, nil
+    }
+    if !included {
+        for _, entry := range schema {
+            if entry.QType != MATRIX {
+                out = append(out, entry.Key)
+            }
+        }
+    }
+    out = slices.DeleteFunc(out, func(key string) bool { return slices.Contains(excluded, key) })
+    if len(out) == 0 {
+        return nil, fmt.Errorf("all questions are excluded")
+    }
+    return out, nil
 }
 
 func (rq *ResponseQuery) Limit(responses []Response) []Response {
-    n := len(responses)
-    startIndex := 0
-    endIndex := n - 1
+    return limitSlice(rq.Range, responses)
+}
 
-    switch rq.Range.Start.Type {
-    case "first":
-        startIndex = 0 + rq.Range.Start.Offset
-    case "last":
-        startIndex = (n - 1) + rq.Range.Start.Offset
-    case "index":
-        startIndex = rq.Range.Start.Offset
+// LimitRows applies the range to a list of row indices.
+func (rq *ResponseQuery) LimitRows(rows []int) []int {
+    return limitSlice(rq.Range, rows)
+}
+
+func limitSlice[T any](rng RangeSelector, items []T) []T {
+    n := len(items)
+    if len(rng.More) == 0 && rng.Step <= 1 {
+        start, end, ok := rng.bounds(n)
+        if !ok {
+            return []T{}
+        }
+        return items[start : end+1]
     }
-    switch rq.Range.End.Type {
-    case "first":
-        endIndex = 0 + rq.Range.End.Offset
-    case "last":
-        endIndex = (n - 1) + rq.Range.End.Offset
-    case "index":
-        endIndex = rq.Range.End.Offset
+    // Positions selected by several ranges are taken once, at their first
+    // range
+    out := []T{}
+    seen := make(map[int]bool)
+    for _, r := range append([]RangeSelector{rng}, rng.More...) {
+        start, end, ok := r.bounds(n)
+        if !ok {
+            continue
+        }
+        for i := start; i <= end; i += max(r.Step, 1) {
+            if !seen[i] {
+                seen[i] = true
+                out = append(out, items[i])
+            }
+        }
     }
+    return out
+}
+
+// bounds returns the first and last index the range selects from n items,
+// ignoring its step and further ranges.
+func (rng RangeSelector) bounds(n int) (start, end int, ok bool) {
+    start = rng.Start.index(n, false)
+    end = rng.End.index(n, true)
     // Clamp indices
-    if startIndex < 0 {
-        startIndex = 0
+    if start < 0 {
+        start = 0
+    }
+    if end >= n {
+        end = n - 1
     }
-    if endIndex >= n {
-        endIndex = n - 1
+    if end < start || start >= n {
+        return 0, 0, false
+    }
+    return start, end, true
+}
+
+// index resolves the endpoint for n items. A percentage at the end of a
+// range is exclusive, so that [0%..10%] and [10%..20%] don't overlap.
+func (e RangeEndpoint) index(n int, isEnd bool) int {
+    switch e.Type {
+    case "first":
+        return e.Offset
+    case "last":
+        return n - 1 + e.Offset
+    case "index":
+        return e.Offset
+    case "percent":
+        i := int(math.Floor(e.Percent * float64(n) / 100))
+        if isEnd {
+            i--
+        }
+        return i
     }
-    if endIndex < startIndex || startIndex >= n {
-        return []Response{}
+    if isEnd {
+        return n - 1
     }
-    return responses[startIndex : endIndex+1]
+    return 0
 }
 
 func AllResponseQuery() *ResponseQuery {
@@ -60,12 +297,54 @@ func AllResponseQuery() *ResponseQuery {
 type RangeSelector struct {
     Start RangeEndpoint
     End   RangeEndpoint
+    // Step selects every Step-th item from Start on; 0 and 1 select all.
+    Step int
+    // More lists further ranges of a list like [0..10, 100..110], which
+    // select items after the ones of the first range.
+    More []RangeSelector
+}
+
+// isAll reports whether the range selects all items, like the range of
+// AllResponseQuery.
+func (rng RangeSelector) isAll() bool {
+    return rng.Start.Type == "first" && rng.Start.Offset == 0 &&
+        rng.End.Type == "last" && rng.End.Offset == 0 &&
+        rng.Step == 0 && len(rng.More) == 0
+}
+
+// String returns the range in query syntax, e.g. [first..first+9, 90%..last:10].
+func (rng RangeSelector) String() string {
+    spans := make([]string, 0, 1+len(rng.More))
+    for _, r := range append([]RangeSelector{rng}, rng.More...) {
+        span := r.Start.String() + ".." + r.End.String()
+        if r.Step != 0 {
+            span += ":" + strconv.Itoa(r.Step)
+        }
+        spans = append(spans, span)
+    }
+    return "[" + strings.Join(spans, ", ") + "]"
 }
 
 type RangeEndpoint struct {
-    Type      string // "first", "last", "index"
-    Offset    int    // applies to first/last, e.g., +2 or -3
-    RawString string // original representation for debugging
+    Type      string  // "first", "last", "index", "percent"
+    Offset    int     // applies to first/last, e.g., +2 or -3
+    Percent   float64 // applies to percent, e.g. 10 for 10%
+    RawString string  // original representation for debugging
+}
+
+// String returns the endpoint in query syntax. Unlike RawString it is
+// canonical: first+0 is written as first.
+func (e RangeEndpoint) String() string {
+    switch e.Type {
+    case "first", "last":
+        if e.Offset == 0 {
+            return e.Type
+        }
+        return fmt.Sprintf("%s%+d", e.Type, e.Offset)
+    case "percent":
+        return strconv.FormatFloat(e.Percent, 'f', -1, 64) + "%"
+    }
+    return strconv.Itoa(e.Offset)
 }
 
 func ParseResponseQuery(input string) (*ResponseQuery, error) {
@@ -78,13 +357,42 @@ func ParseResponseQuery(input string) (*ResponseQuery, error) {
     // Support both single-line and multi-line variants
     sections := splitSections(input)
 
-    var keysSection, rangeSection string
+    var keysSection, rangeSection, idsSection string
+    var where *Filter
+    var sortKeys []SortKey
+    var sample *Sample
+    offset := 0 // byte offset of the section in input
     for _, sec := range sections {
+        secOffset := offset
+        offset += len(sec) + 1
+        secOffset += len(sec) - len(strings.TrimLeftFunc(sec, unicode.IsSpace))
         sec = strings.TrimSpace(sec)
-        if strings.HasPrefix(sec, "keys:") || strings.HasPrefix(sec, "keys=") {
+        if strings.HasPrefix(sec, "where:") || strings.HasPrefix(sec, "where=") {
+            // Both separators have the same length
+            start := utf8.RuneCountInString(input[:secOffset+len("where:")])
+            f, err := parseFilter(input[:secOffset+len(sec)], start)
+            if err != nil {
+                return nil, fmt.Errorf("where: %w", err)
+            }
+            where = f
+        } else if strings.HasPrefix(sec, "keys:") || strings.HasPrefix(sec, "keys=") {
             keysSection = sec
         } else if strings.HasPrefix(sec, "range:") || strings.HasPrefix(sec, "range=") {
             rangeSection = sec
+        } else if strings.HasPrefix(sec, "ids:") || strings.HasPrefix(sec, "ids=") {
+            idsSection = sec
+        } else if strings.HasPrefix(sec, "sort:") || strings.HasPrefix(sec, "sort=") {
+            keys, err := parseSort(sec)
+            if err != nil {
+                return nil, fmt.Errorf("sort: %w", err)
+            }
+            sortKeys = keys
+        } else if strings.HasPrefix(sec, "sample:") || strings.HasPrefix(sec, "sample=") {
+            s, err := parseSample(sec)
+            if err != nil {
+                return nil, fmt.Errorf("sample: %w", err)
+            }
+            sample = s
         }
     }
 
@@ -99,6 +407,14 @@ func ParseResponseQuery(input string) (*ResponseQuery, error) {
         keys = nil
     }
 
+    var ids []IDRange
+    if idsSection != "" {
+        ids, err = parseIDs(idsSection)
+        if err != nil {
+            return nil, fmt.Errorf("ids: %w", err)
+        }
+    }
+
     var rng *RangeSelector
     if rangeSection != "" {
         rng, err = parseRange(rangeSection)
@@ -115,32 +431,142 @@ func ParseResponseQuery(input string) (*ResponseQuery, error) {
         }
     }
 
-    return &ResponseQuery{Keys: keys, Range: *rng}, nil
+    return &ResponseQuery{Keys: keys, Range: *rng, IDs: ids, Where: where, Sample: sample, Sort: sortKeys}, nil
 }
 
 func splitSections(input string) []string {
     // Accept both ; and newlines as section separators
-    parts := strings.Split(input, ";")
+    parts := splitOutsideQuotes(input, ';')
     if len(parts) == 1 {
-        parts = strings.Split(input, "\n")
+        parts = splitOutsideQuotes(input, '\n')
     }
     return parts
 }
 
-func parseKeys(sec string) ([]string, error) {
-    sep := ":"
-    if strings.Contains(sec, "=") {
-        sep = "="
-    }
-    parts := strings.SplitN(sec, sep, 2)
-    if len(parts) != 2 {
-        return nil, errors.New("invalid keys section")
+// splitOutsideQuotes splits s at the separator where it isn't part of a
+// quoted string or a bracketed key. Quotes and brackets only count at the
+// start of a word, so that keys like Don't stay unquoted. Quotes are ended
+// by the same quote, doubled quotes and \" are kept inside, as are doubled
+// ]] in brackets.
+func splitOutsideQuotes(s string, sep byte) []string {
+    var parts []string
+    start := 0
+    quote := byte(0)
+    inBrackets := false
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        wordStart := i == 0 || strings.IndexByte(" \t\n,:=(@-!", s[i-1]) >= 0
+        switch {
+        case quote != 0:
+            if c == '\\' && quote == '"' && i+1 < len(s) || c == quote && i+1 < len(s) && s[i+1] == quote {
+                i++
+            } else if c == quote {
+                quote = 0
+            }
+        case inBrackets:
+            if c == ']' && i+1 < len(s) && s[i+1] == ']' {
+                i++
+            } else {
+                inBrackets = c != ']'
+            }
+        case (c == '\'' || c == '"') && wordStart:
+            quote = c
+        case c == '[' && wordStart:
+            inBrackets = true
+        case c == sep:
+            parts = append(parts, s[start:i])
+            start = i + 1
+        }
     }
-    raw := strings.TrimSpace(parts[1])
+    return append(parts, s[start:])
+}
+
+func parseKeys(sec string) ([]string, error) {
+    // Both "keys:" and "keys=" have the same length; the separator may
+    // appear again in patterns and type selectors
+    raw := strings.TrimSpace(sec[len("keys:"):])
     if raw == "" {
         return nil, errors.New("no keys specified")
     }
+    out := parseList(raw)
+    if len(out) == 0 {
+        return nil, errors.New("no keys specified")
+    }
+    for _, sel := range out {
+        if err := checkKeySelector(sel); err != nil {
+            return nil, err
+        }
+    }
+    return out, nil
+}
+
+// parseIDs parses the ids section: a list of respondent IDs and ID ranges
+// like 17, 42, 100..200. IDs may be quoted like keys; a ".." inside quotes
+// is part of the ID.
+func parseIDs(sec string) ([]IDRange, error) {
+    // Both "ids:" and "ids=" have the same length
+    var out []IDRange
+    for _, item := range splitIDs(strings.TrimSpace(sec[len("ids:"):])) {
+        ends := make([]string, len(item))
+        for i, part := range item {
+            switch ids := parseList(part); len(ids) {
+            case 0:
+            case 1:
+                ends[i] = ids[0]
+            default:
+                return nil, fmt.Errorf("invalid ID %q", strings.TrimSpace(part))
+            }
+        }
+        switch {
+        case len(ends) == 1 && strings.TrimSpace(item[0]) == "":
+            continue
+        case len(ends) == 1:
+            out = append(out, IDRange{From: ends[0], To: ends[0]})
+        case len(ends) == 2 && ends[0] != "" && ends[1] != "":
+            out = append(out, IDRange{From: ends[0], To: ends[1]})
+        default:
+            return nil, fmt.Errorf("ID range %q needs a start and an end", strings.TrimSpace(strings.Join(item, "..")))
+        }
+    }
+    if len(out) == 0 {
+        return nil, errors.New("no IDs specified")
+    }
+    return out, nil
+}
 
+// splitIDs splits the ids section into its comma separated items, and
+// each item at "..", where these aren't inside quotes. The parts are
+// returned as written, with their quotes.
+func splitIDs(raw string) [][]string {
+    var items [][]string
+    var parts []string
+    start := 0
+    quote := byte(0)
+    for i := 0; i < len(raw); i++ {
+        c := raw[i]
+        switch {
+        case quote != 0:
+            if c == '\\' && quote == '"' && i+1 < len(raw) && raw[i+1] == '"' || c == quote && i+1 < len(raw) && raw[i+1] == quote {
+                i++
+            } else if c == quote {
+                quote = 0
+            }
+        case (c == '\'' || c == '"') && strings.TrimSpace(raw[start:i]) == "":
+            quote = c
+        case c == ',':
+            items = append(items, append(parts, raw[start:i]))
+            parts, start = nil, i+1
+        case strings.HasPrefix(raw[i:], ".."):
+            parts = append(parts, raw[start:i])
+            start = i + 2
+            i++
+        }
+    }
+    return append(items, append(parts, raw[start:]))
+}
+
+// parseList splits a comma separated list of quoted and unquoted items.
+func parseList(raw string) []string {
     // Custom parser for quoted and unquoted keys
     var out []string
     i := 0
@@ -152,6 +578,20 @@ func parseKeys(sec string) ([]string, error) {
         if i >= len(raw) {
             break
         }
+        // A block reference or exclusion may quote the name that follows:
+        // @"Basic information", -"Work mode", -@"Basic information"
+        prefix := ""
+        j := i
+        if raw[j] == ExcludePrefix[0] {
+            j++
+        }
+        if j < len(raw) && raw[j] == BlockPrefix[0] {
+            j++
+        }
+        if j > i && j < len(raw) && (raw[j] == '\'' || raw[j] == '"') {
+            prefix = raw[i:j]
+            i = j
+        }
         if raw[i] == '\'' || raw[i] == '"' {
             quote := raw[i]
             i++
@@ -176,7 +616,7 @@ func parseKeys(sec string) ([]string, error) {
                 sb.WriteByte(raw[i])
                 i++
             }
-            out = append(out, sb.String())
+            out = append(out, prefix+sb.String())
         } else {
             // Unquoted key: read until comma
             start := i
@@ -193,11 +633,7 @@ func parseKeys(sec string) ([]string, error) {
             i++
         }
     }
-
-    if len(out) == 0 {
-        return nil, errors.New("no keys specified")
-    }
-    return out, nil
+    return out
 }
 
 func parseRange(sec string) (*RangeSelector, error) {
@@ -215,6 +651,33 @@ func parseRange(sec string) (*RangeSelector, error) {
     }
     raw = strings.TrimPrefix(raw, "[")
     raw = strings.TrimSuffix(raw, "]")
+    var out *RangeSelector
+    for _, part := range strings.Split(raw, ",") {
+        rng, err := parseRangeSpan(part)
+        if err != nil {
+            return nil, err
+        }
+        if out == nil {
+            out = rng
+        } else {
+            out.More = append(out.More, *rng)
+        }
+    }
+    return out, nil
+}
+
+// parseRangeSpan parses a single range of a range list: <start>..<end>,
+// optionally followed by :<step>.
+func parseRangeSpan(raw string) (*RangeSelector, error) {
+    raw, stepStr, hasStep := strings.Cut(raw, ":")
+    step := 0
+    if hasStep {
+        var err error
+        step, err = strconv.Atoi(strings.TrimSpace(stepStr))
+        if err != nil || step < 1 {
+            return nil, fmt.Errorf("invalid step %q, must be a positive integer", strings.TrimSpace(stepStr))
+        }
+    }
     rangeParts := strings.SplitN(raw, "..", 2)
     if len(rangeParts) != 2 {
         return nil, errors.New("range must use .. to separate start and end")
@@ -227,10 +690,10 @@ func parseRange(sec string) (*RangeSelector, error) {
     if err != nil {
         return nil, fmt.Errorf("invalid end: %w", err)
     }
-    return &RangeSelector{Start: start, End: end}, nil
+    return &RangeSelector{Start: start, End: end, Step: step}, nil
 }
 
-var endpointRe = regexp.MustCompile(`^(first|last)([+-]\d+)?$|^(\d+)$`)
+var endpointRe = regexp.MustCompile(`^(first|last)([+-]\d+)?$|^(\d+)$|^(\d+(?:\.\d+)?)%$`)
 
 func parseRangeEndpoint(s string) (RangeEndpoint, error) {
     s = strings.TrimSpace(s)
@@ -239,7 +702,7 @@ func parseRangeEndpoint(s string) (RangeEndpoint, error) {
     }
     m := endpointRe.FindStringSubmatch(s)
     if m == nil {
-        return RangeEndpoint{RawString: s}, errors.New("must be first, last, or integer (with optional +N/-N for first/last)")
+        return RangeEndpoint{RawString: s}, errors.New("must be first, last, an integer or a percentage (with optional +N/-N for first/last)")
     }
     if m[1] != "" { // first or last
         offset := 0
@@ -267,5 +730,16 @@ func parseRangeEndpoint(s string) (RangeEndpoint, error) {
             RawString: s,
         }, nil
     }
+    if m[4] != "" { // percentage
+        pct, err := strconv.ParseFloat(m[4], 64)
+        if err != nil || pct > 100 {
+            return RangeEndpoint{RawString: s}, fmt.Errorf("invalid percentage %q", s)
+        }
+        return RangeEndpoint{
+            Type:      "percent",
+            Percent:   pct,
+            RawString: s,
+        }, nil
+    }
     return RangeEndpoint{RawString: s}, errors.New("unrecognized endpoint")
 }
@@ -0,0 +1,163 @@
+package survey
+
+import (
+    "math/rand/v2"
+    "reflect"
+    "testing"
+)
+
+func TestResponseQuery_String(t *testing.T) {
+    tests := []struct {
+        input, want string
+    }{
+        {"", ""},
+        {"range:[first+0..last-0]; keys:a ,b", "keys: a, b"},
+        {"range=[0..5:2,10%..20%]; keys=x", "keys: x; range: [0..5:2, 10%..20%]"},
+        {`keys: 'Work mode', "it's", @"Basic information", -'a,b'`, `keys: Work mode, "it's", @Basic information, -"a,b"`},
+        {"ids: 17, 100..200", "ids: 17, 100..200"},
+        {`ids: 'a..b', "x" .. 'y', "1,2"..3`, `ids: "a..b", x..y, "1,2"..3`},
+        {"where: NOT (a == 1 OR b = 2) && c has any (x)", "where: not (a = 1 or b = 2) and c has x"},
+        {"where: a = 1 or (b = 2 and c is not na)", "where: a = 1 or b = 2 and c is not na"},
+        {"where: (a = 1 or b = 2) and not c in (x, 'y z')", `where: (a = 1 or b = 2) and c not in (x, "y z")`},
+        {`where: [Work mode] = 'has; semicolon' and [in] has all (any)`, `where: [Work mode] = "has; semicolon" and [in] has all ("any")`},
+        {"sample: 5% by Country seed 1", "sample: 5% per Country"},
+        {"sample: 0.25 per 'Work mode' seed 9", `sample: 25% per "Work mode" seed 9`},
+        {"sort: Age ASC NA LAST, Years DESC na first", "sort: Age, Years desc na first"},
+        {"range: [first..last]; sort: Age; where: Age is na; keys: Age", "keys: Age; where: Age is na; sort: Age"},
+    }
+    for _, tt := range tests {
+        q, err := ParseResponseQuery(tt.input)
+        if err != nil {
+            t.Errorf("ParseResponseQuery(%q) failed: %v", tt.input, err)
+            continue
+        }
+        if got := q.String(); got != tt.want {
+            t.Errorf("ParseResponseQuery(%q).String() = %q, want %q", tt.input, got, tt.want)
+        }
+    }
+}
+
+// TestResponseQuery_StringRoundTrip checks that random queries read back
+// from their canonical form are the same query.
+func TestResponseQuery_StringRoundTrip(t *testing.T) {
+    rng := rand.New(rand.NewPCG(1, 2))
+    for i := 0; i < 2000; i++ {
+        q := randomQuery(rng)
+        s := q.String()
+        parsed, err := ParseResponseQuery(s)
+        if err != nil {
+            t.Fatalf("ParseResponseQuery(%q) failed: %v", s, err)
+        }
+        if !reflect.DeepEqual(parsed, q) {
+            t.Fatalf("%q reads back as a different query:\n got %+v\nwant %+v", s, parsed, q)
+        }
+        if again := parsed.String(); again != s {
+            t.Fatalf("String is not stable: %q, then %q", s, again)
+        }
+    }
+}
+
+// Keys and values that need quoting, escaping or brackets
+var (
+    roundTripKeys = []string{
+        "Age", "Work mode", "K_1", "it's", `say "hi"`, " padded ", "semi;colon", "comma,key", "new\nline",
+        "@AI", "@Basic information", "@a,b", "-TE*", "-@x y", ":MC", "-:te", "Language*", "/^A.*$/", "-", "",
+    }
+    roundTripFilterKeys = []string{"Age", "Work mode", "not", "In", "K_1", "1st", "Ünïcode", "a;b", `quo"te`, "a]b", "[x]", "a]];b"}
+    roundTripValues     = []string{
+        "Germany", "United States", "35-44 years old", "10", "-3", "2.5", ".5", "and", "ANY", "it's",
+        `a "q" \ b`, "", "x;y", "(paren)", "Ünïcode",
+    }
+    roundTripIDs = []IDRange{{"17", "17"}, {"1", "100"}, {"a b", "a b"}, {"x,1", "y"}, {"R_1", "R_9"}, {"-5", "-5"}, {"a..b", "a..b"}, {"1..2", "3"}, {"-x,1", "-x,1"}}
+)
+
+func pick[T any](rng *rand.Rand, list []T) T {
+    return list[rng.IntN(len(list))]
+}
+
+func randomQuery(rng *rand.Rand) *ResponseQuery {
+    q := AllResponseQuery()
+    if rng.IntN(2) == 0 {
+        for n := 1 + rng.IntN(3); n > 0; n-- {
+            q.Keys = append(q.Keys, pick(rng, roundTripKeys))
+        }
+    }
+    if rng.IntN(3) == 0 {
+        for n := 1 + rng.IntN(3); n > 0; n-- {
+            q.IDs = append(q.IDs, pick(rng, roundTripIDs))
+        }
+    }
+    if rng.IntN(2) == 0 {
+        q.Where = &Filter{root: randomFilter(rng, 3)}
+    }
+    if rng.IntN(3) == 0 {
+        s := &Sample{Seed: pick(rng, []uint64{DefaultSampleSeed, 7, 123456789})}
+        if rng.IntN(2) == 0 {
+            s.Size = rng.IntN(1000)
+        } else {
+            s.Fraction = pick(rng, []float64{0.05, 0.1, 1.0 / 3, 1, 0.125, 0.000001})
+        }
+        if rng.IntN(2) == 0 {
+            s.By = pick(rng, roundTripFilterKeys)
+        }
+        q.Sample = s
+    }
+    if rng.IntN(3) == 0 {
+        for n := 1 + rng.IntN(3); n > 0; n-- {
+            key := pick(rng, roundTripFilterKeys)
+            q.Sort = append(q.Sort, SortKey{Key: key, Desc: rng.IntN(2) == 0, NAFirst: rng.IntN(2) == 0})
+        }
+    }
+    if rng.IntN(2) == 0 {
+        spans := make([]RangeSelector, 1+rng.IntN(3))
+        for i := range spans {
+            spans[i] = RangeSelector{
+                Start: randomEndpoint(rng),
+                End:   randomEndpoint(rng),
+                Step:  pick(rng, []int{0, 1, 5, 100}),
+            }
+        }
+        q.Range = spans[0]
+        if len(spans) > 1 {
+            q.Range.More = spans[1:]
+        }
+    }
+    return q
+}
+
+func randomEndpoint(rng *rand.Rand) RangeEndpoint {
+    var e RangeEndpoint
+    switch rng.IntN(4) {
+    case 0:
+        e = RangeEndpoint{Type: "first", Offset: rng.IntN(7) - 3}
+    case 1:
+        e = RangeEndpoint{Type: "last", Offset: rng.IntN(7) - 3}
+    case 2:
+        e = RangeEndpoint{Type: "index", Offset: rng.IntN(50)}
+    default:
+        e = RangeEndpoint{Type: "percent", Percent: pick(rng, []float64{0, 12.5, 50, 100, 33.3})}
+    }
+    e.RawString = e.String()
+    return e
+}
+
+func randomFilter(rng *rand.Rand, depth int) filterNode {
+    switch n := rng.IntN(4); {
+    case depth > 0 && n == 0:
+        return &filterLogic{pick(rng, []string{"and", "or"}), randomFilter(rng, depth-1), randomFilter(rng, depth-1)}
+    case depth > 0 && n == 1:
+        return &filterNot{randomFilter(rng, depth-1)}
+    }
+    c := &filterCond{key: pick(rng, roundTripFilterKeys)}
+    c.op = pick(rng, []string{"is na", "=", "!=", "<", "<=", ">", ">=", "in", "has any", "has all"})
+    switch c.op {
+    case "is na":
+    case "in", "has any", "has all":
+        for n := 1 + rng.IntN(3); n > 0; n-- {
+            c.values = append(c.values, pick(rng, roundTripValues))
+        }
+    default:
+        c.values = []string{pick(rng, roundTripValues)}
+    }
+    return c
+}
@@ -196,3 +196,69 @@ func TestResponseQuery_Limit(t *testing.T) {
 		})
 	}
 }
+
+func TestResponseQuery_ResolveKeys(t *testing.T) {
+	schema := Schema{
+		{Key: "Age", QType: SC, Block: "Basic information"},
+		{Key: "Country", QType: SC, Block: "Basic information"},
+		{Key: "AISelect", QType: SC, Block: "AI"},
+	}
+	q, err := ParseResponseQuery(`keys: AISelect, @"Basic information", Age`)
+	if err != nil {
+		t.Fatalf("ParseResponseQuery failed: %v", err)
+	}
+	if want := []string{"AISelect", "@Basic information", "Age"}; !reflect.DeepEqual(q.Keys, want) {
+		t.Errorf("Keys = %q, want %q", q.Keys, want)
+	}
+	keys, err := q.ResolveKeys(schema)
+	if want := []string{"AISelect", "Age", "Country"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("ResolveKeys = %q, %v; want %q", keys, err, want)
+	}
+
+	q, _ = ParseResponseQuery("keys: @Tools")
+	if _, err := q.ResolveKeys(schema); err == nil {
+		t.Error("expected error for an unknown block")
+	}
+}
+
+func TestResponseQuery_LimitRanges(t *testing.T) {
+	rows := make([]int, 20)
+	for i := range rows {
+		rows[i] = i
+	}
+	tests := []struct {
+		rng  string
+		want []int
+	}{
+		{"[0..2, 10..11]", []int{0, 1, 2, 10, 11}},
+		{"[last-1..last, first..first]", []int{18, 19, 0}},
+		{"[0..5, 3..7]", []int{0, 1, 2, 3, 4, 5, 6, 7}}, // overlaps are taken once
+		{"[first..last:5]", []int{0, 5, 10, 15}},
+		{"[3..10:4, 18..30]", []int{3, 7, 18, 19}},
+		{"[10%..20%]", []int{2, 3}},
+		{"[0%..10%, 90%..100%]", []int{0, 1, 18, 19}},
+		{"[95%..last]", []int{19}},
+		{"[50..60, 0..0]", []int{0}},
+	}
+	for _, tt := range tests {
+		q, err := ParseResponseQuery("range: " + tt.rng)
+		if err != nil {
+			t.Errorf("ParseResponseQuery(%q) failed: %v", tt.rng, err)
+			continue
+		}
+		if got := q.LimitRows(rows); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("range %s = %v, want %v", tt.rng, got, tt.want)
+		}
+	}
+
+	// The default query still selects everything
+	if got := AllResponseQuery().LimitRows(rows); !reflect.DeepEqual(got, rows) {
+		t.Errorf("AllResponseQuery selects %v", got)
+	}
+
+	for _, bad := range []string{"range: [0..5,]", "range: [0..5:0]", "range: [0..5:x]", "range: [10%..150%]", "range: [first+10%..last]"} {
+		if _, err := ParseResponseQuery(bad); err == nil {
+			t.Errorf("ParseResponseQuery(%q) should fail", bad)
+		}
+	}
+}
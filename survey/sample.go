@@ -0,0 +1,170 @@
+package survey
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "math/rand/v2"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// Sample draws a random sample of responses in the sample section of a
+// ResponseQuery:
+//
+//	sample: 100                    100 random responses
+//	sample: 5%                     5% of the responses (also 0.05)
+//	sample: 10 per Country         10 responses per country
+//	sample: 5% per Country seed 7  5% of each country, drawn with seed 7
+//
+// Samples are drawn with a seeded generator, so a query always selects the
+// same responses from the same data. A stratified sample draws from the
+// responses with each answer to an SC question separately; responses
+// without an answer form a stratum of their own.
+type Sample struct {
+    Size     int     // number of responses, if Fraction is 0
+    Fraction float64 // share of the responses, between 0 and 1
+    By       string  // SC question to stratify by, empty for a simple sample
+    Seed     uint64
+}
+
+// DefaultSampleSeed is the seed of samples that don't give one.
+const DefaultSampleSeed = 1
+
+// String returns the sample in query syntax. Shares are written as
+// percentages if that reads back as the same share.
+func (s *Sample) String() string {
+    size := strconv.Itoa(s.Size)
+    if s.Fraction > 0 {
+        pct := strconv.FormatFloat(s.Fraction*100, 'f', 10, 64)
+        pct = strings.TrimRight(strings.TrimRight(pct, "0"), ".")
+        if f, err := strconv.ParseFloat(pct, 64); err == nil && f/100 == s.Fraction {
+            size = pct + "%"
+        } else {
+            size = strconv.FormatFloat(s.Fraction, 'f', -1, 64)
+        }
+    }
+    if s.By != "" {
+        size += " per " + quoteWord(s.By)
+    }
+    if s.Seed != DefaultSampleSeed {
+        size += " seed " + strconv.FormatUint(s.Seed, 10)
+    }
+    return size
+}
+
+// parseSample parses the sample section.
+func parseSample(sec string) (*Sample, error) {
+    // Both "sample:" and "sample=" have the same length
+    groups, err := splitWords(strings.TrimSpace(sec[len("sample:"):]))
+    if err != nil {
+        return nil, err
+    }
+    if len(groups) != 1 || len(groups[0]) == 0 {
+        return nil, errors.New("use <n>|<percent>% [per <key>] [seed <n>]")
+    }
+    words := groups[0]
+    s := &Sample{Seed: DefaultSampleSeed}
+    size := words[0]
+    if pct, ok := strings.CutSuffix(size, "%"); ok {
+        f, err := strconv.ParseFloat(pct, 64)
+        if err != nil || f <= 0 || f > 100 {
+            return nil, fmt.Errorf("invalid percentage %q", size)
+        }
+        s.Fraction = f / 100
+    } else if n, err := strconv.Atoi(size); err == nil && n >= 0 {
+        s.Size = n
+    } else if f, err := strconv.ParseFloat(size, 64); err == nil && f > 0 && f < 1 {
+        s.Fraction = f
+    } else {
+        return nil, fmt.Errorf("invalid sample size %q, use a count, a percentage or a fraction", size)
+    }
+
+    for i := 1; i < len(words); i += 2 {
+        if i+1 >= len(words) {
+            return nil, fmt.Errorf("missing value after %q", words[i])
+        }
+        switch strings.ToLower(words[i]) {
+        case "per", "by":
+            s.By = words[i+1]
+        case "seed":
+            seed, err := strconv.ParseUint(words[i+1], 10, 64)
+            if err != nil {
+                return nil, fmt.Errorf("invalid seed %q", words[i+1])
+            }
+            s.Seed = seed
+        default:
+            return nil, fmt.Errorf("unexpected %q, use per <key> or seed <n>", words[i])
+        }
+    }
+    return s, nil
+}
+
+// draw returns the sampled rows in their original order.
+func (s *Sample) draw(sd *SurveyData, rows []int) ([]int, error) {
+    rng := rand.New(rand.NewPCG(s.Seed, 0))
+    if s.By == "" {
+        return s.drawFrom(rng, rows), nil
+    }
+
+    entry, ok := sd.Schema.Get(s.By)
+    if !ok {
+        return nil, fmt.Errorf("unknown question %q", s.By)
+    }
+    if entry.QType != SC {
+        return nil, fmt.Errorf("can't stratify by %s question %q, use a single-choice question", entry.QType, s.By)
+    }
+    col, ok := sd.Column(s.By)
+    if !ok {
+        return s.drawFrom(rng, rows), nil
+    }
+    // Strata hold positions in rows and are indexed by option code, with
+    // missing answers last
+    strata := make([][]int, len(entry.UsedOptions)+1)
+    for i, row := range rows {
+        code := col.Code(row)
+        if code < 0 {
+            code = len(entry.UsedOptions)
+        }
+        strata[code] = append(strata[code], i)
+    }
+    var drawn []int
+    for _, stratum := range strata {
+        drawn = append(drawn, s.drawFrom(rng, stratum)...)
+    }
+    slices.Sort(drawn)
+    out := make([]int, len(drawn))
+    for i, p := range drawn {
+        out[i] = rows[p]
+    }
+    return out, nil
+}
+
+// drawFrom draws the sample size from rows without replacement, keeping
+// the order of rows.
+func (s *Sample) drawFrom(rng *rand.Rand, rows []int) []int {
+    k := s.Size
+    if s.Fraction > 0 {
+        k = int(math.Round(s.Fraction * float64(len(rows))))
+    }
+    if k >= len(rows) {
+        return slices.Clone(rows)
+    }
+    // Partial Fisher-Yates shuffle of the positions
+    pos := make([]int, len(rows))
+    for i := range pos {
+        pos[i] = i
+    }
+    for i := 0; i < k; i++ {
+        j := i + rng.IntN(len(pos)-i)
+        pos[i], pos[j] = pos[j], pos[i]
+    }
+    pos = pos[:k]
+    slices.Sort(pos)
+    out := make([]int, k)
+    for i, p := range pos {
+        out[i] = rows[p]
+    }
+    return out
+}
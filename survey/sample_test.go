@@ -0,0 +1,107 @@
+package survey
+
+import (
+    "reflect"
+    "slices"
+    "testing"
+)
+
+func sampleTestData() *SurveyData {
+    // 50 A, 30 B, 5 C and 15 without an answer, interleaved
+    var responses []Response
+    for i := 0; i < 100; i++ {
+        var group any
+        switch {
+        case i%2 == 0:
+            group = "A"
+        case i%10 == 1 || i%10 == 5 || i%10 == 9:
+            group = "B"
+        case i%20 == 3:
+            group = "C"
+        }
+        responses = append(responses, Response{"Group": {Val: group}, "Tags": {Val: []string{"x"}}})
+    }
+    return NewSurveyData(Schema{
+        {Key: "Group", Text: "Group", QType: SC},
+        {Key: "Tags", Text: "Tags", QType: MC},
+    }, responses)
+}
+
+func TestResponseQuery_Sample(t *testing.T) {
+    sd := sampleTestData()
+    selectRows := func(query string) []int {
+        t.Helper()
+        q, err := ParseResponseQuery(query)
+        if err != nil {
+            t.Fatalf("ParseResponseQuery(%q) failed: %v", query, err)
+        }
+        rows, err := q.Select(sd, sd.Rows())
+        if err != nil {
+            t.Fatalf("%q failed: %v", query, err)
+        }
+        return rows
+    }
+
+    rows := selectRows("sample: 10")
+    if len(rows) != 10 || !slices.IsSorted(rows) {
+        t.Errorf("sample: 10 = %v, want 10 rows in data order", rows)
+    }
+    if again := selectRows("sample: 10"); !reflect.DeepEqual(again, rows) {
+        t.Errorf("the same seed drew %v, then %v", rows, again)
+    }
+    if other := selectRows("sample: 10 seed 2"); reflect.DeepEqual(other, rows) {
+        t.Errorf("seed 2 drew the same sample %v", other)
+    }
+    if got := selectRows("sample: 25%"); len(got) != 25 {
+        t.Errorf("sample: 25%% has %d rows, want 25", len(got))
+    }
+    if got := selectRows("sample: 0.1"); len(got) != 10 {
+        t.Errorf("sample: 0.1 has %d rows, want 10", len(got))
+    }
+    if got := selectRows("sample: 1000"); len(got) != 100 {
+        t.Errorf("oversized sample has %d rows, want all 100", len(got))
+    }
+
+    // The range applies to the sample, in data order unless sorted
+    if got := selectRows("sample: 10; range: [first..first+2]"); !reflect.DeepEqual(got, rows[:3]) {
+        t.Errorf("ranged sample = %v, want %v", got, rows[:3])
+    }
+
+    countGroups := func(rows []int) map[string]int {
+        counts := map[string]int{}
+        for _, row := range rows {
+            s, ok := sd.Value(row, "Group").AsString()
+            if !ok {
+                s = "n/a"
+            }
+            counts[s]++
+        }
+        return counts
+    }
+    want := map[string]int{"A": 3, "B": 3, "C": 3, "n/a": 3}
+    if got := countGroups(selectRows("sample: 3 per Group")); !reflect.DeepEqual(got, want) {
+        t.Errorf("sample: 3 per Group = %v, want %v", got, want)
+    }
+    want = map[string]int{"A": 5, "B": 3, "C": 1, "n/a": 2}
+    if got := countGroups(selectRows("sample: 10% by Group seed 5")); !reflect.DeepEqual(got, want) {
+        t.Errorf("sample: 10%% by Group = %v, want %v", got, want)
+    }
+    if got := countGroups(selectRows("where: Group in (A, C); sample: 4 per Group")); !reflect.DeepEqual(got, map[string]int{"A": 4, "C": 4}) {
+        t.Errorf("filtered stratified sample = %v", got)
+    }
+
+    for _, bad := range []string{"sample:", "sample: -1", "sample: 150%", "sample: 1.5", "sample: 10 per", "sample: 10 seed x", "sample: 10 each Group"} {
+        if _, err := ParseResponseQuery(bad); err == nil {
+            t.Errorf("ParseResponseQuery(%q) should fail", bad)
+        }
+    }
+    for _, bad := range []string{"sample: 3 per Tags", "sample: 3 per Missing"} {
+        q, err := ParseResponseQuery(bad)
+        if err != nil {
+            t.Fatalf("ParseResponseQuery(%q) failed: %v", bad, err)
+        }
+        if _, err := q.Select(sd, sd.Rows()); err == nil {
+            t.Errorf("%q should fail on the test data", bad)
+        }
+    }
+}
@@ -0,0 +1,234 @@
+package survey
+
+import (
+    "cmp"
+    "errors"
+    "fmt"
+    "slices"
+    "strings"
+)
+
+// SortKey orders responses by the answers to a question in the sort
+// section of a ResponseQuery:
+//
+//	sort: YearsCodePro desc, Country
+//	sort: Age desc na first, "Work mode"
+//
+// Numeric questions are sorted by value, ordinal single-choice questions
+// in the declared order of their options (undeclared options after them),
+// single-choice questions whose options are all numbers or range labels
+// by number, other single-choice and text questions alphabetically,
+// ignoring case.
+// Missing answers come last unless "na first" is given, in either
+// direction.
+type SortKey struct {
+    Key     string
+    Desc    bool
+    NAFirst bool
+}
+
+// String returns the sort key in query syntax, leaving out the defaults
+// asc and na last.
+func (k SortKey) String() string {
+    s := quoteWord(k.Key)
+    if k.Desc {
+        s += " desc"
+    }
+    if k.NAFirst {
+        s += " na first"
+    }
+    return s
+}
+
+// quoteWord quotes a word for splitWords if needed.
+func quoteWord(w string) string {
+    if w != "" && !strings.ContainsAny(w, " \t,;'\"\n") {
+        return w
+    }
+    return `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+}
+
+// parseSort parses the sort section: a comma separated list of keys, each
+// optionally followed by asc or desc and by na first or na last.
+func parseSort(sec string) ([]SortKey, error) {
+    // Both "sort:" and "sort=" have the same length
+    groups, err := splitWords(strings.TrimSpace(sec[len("sort:"):]))
+    if err != nil {
+        return nil, err
+    }
+    if len(groups) == 0 {
+        return nil, errors.New("no sort keys specified")
+    }
+    out := make([]SortKey, 0, len(groups))
+    for _, words := range groups {
+        if len(words) == 0 {
+            return nil, errors.New("empty sort key")
+        }
+        key := SortKey{Key: words[0]}
+        mods := strings.ToLower(strings.Join(words[1:], " "))
+        switch {
+        case mods == "", mods == "asc":
+        case mods == "desc":
+            key.Desc = true
+        default:
+            dir, na, _ := strings.Cut(mods, "na ")
+            dir = strings.TrimSpace(dir)
+            if dir != "" && dir != "asc" && dir != "desc" || na != "first" && na != "last" {
+                return nil, fmt.Errorf("invalid order %q for %q, use [asc|desc] [na first|na last]", strings.Join(words[1:], " "), key.Key)
+            }
+            key.Desc = dir == "desc"
+            key.NAFirst = na == "first"
+        }
+        out = append(out, key)
+    }
+    return out, nil
+}
+
+// splitWords splits a comma separated list into groups of whitespace
+// separated words. Words may be quoted with single or double quotes,
+// doubling the quote to include it.
+func splitWords(raw string) ([][]string, error) {
+    var groups [][]string
+    var words []string
+    for i := 0; i < len(raw); {
+        c := raw[i]
+        switch {
+        case c == ' ' || c == '\t':
+            i++
+        case c == ',':
+            groups = append(groups, words)
+            words = nil
+            i++
+        case c == '\'' || c == '"':
+            var sb strings.Builder
+            closed := false
+            for i++; i < len(raw); i++ {
+                if raw[i] == c {
+                    if i+1 < len(raw) && raw[i+1] == c {
+                        sb.WriteByte(c)
+                        i++
+                        continue
+                    }
+                    i++
+                    closed = true
+                    break
+                }
+                sb.WriteByte(raw[i])
+            }
+            if !closed {
+                return nil, errors.New("unterminated quote")
+            }
+            words = append(words, sb.String())
+        default:
+            start := i
+            for i < len(raw) && !strings.ContainsRune(" \t,'\"", rune(raw[i])) {
+                i++
+            }
+            words = append(words, raw[start:i])
+        }
+    }
+    if len(words) > 0 || len(groups) > 0 {
+        groups = append(groups, words)
+    }
+    return groups, nil
+}
+
+// rowCompare orders two responses; it returns a negative number if row a
+// comes first.
+type rowCompare func(a, b int) int
+
+// sortRows sorts rows stably by the sort keys.
+func sortRows(sd *SurveyData, keys []SortKey, rows []int) ([]int, error) {
+    compares := make([]rowCompare, len(keys))
+    for i, key := range keys {
+        c, err := compileSortKey(sd, key)
+        if err != nil {
+            return nil, err
+        }
+        compares[i] = c
+    }
+    sorted := slices.Clone(rows)
+    slices.SortStableFunc(sorted, func(a, b int) int {
+        for _, c := range compares {
+            if r := c(a, b); r != 0 {
+                return r
+            }
+        }
+        return 0
+    })
+    return sorted, nil
+}
+
+func compileSortKey(sd *SurveyData, key SortKey) (rowCompare, error) {
+    entry, ok := sd.Schema.Get(key.Key)
+    if !ok {
+        return nil, fmt.Errorf("unknown question %q", key.Key)
+    }
+    col, ok := sd.Column(key.Key)
+    if !ok {
+        return func(int, int) int { return 0 }, nil
+    }
+
+    // compareValues compares two present answers in ascending order
+    var compareValues rowCompare
+    switch entry.QType {
+    case NUM:
+        compareValues = func(a, b int) int {
+            x, _ := col.Float(a)
+            y, _ := col.Float(b)
+            return cmp.Compare(x, y)
+        }
+    case SC:
+        // Codes follow the declared options, then the undeclared ones
+        // alphabetically, which is the order of an ordinal scale
+        if entry.Ordinal && len(entry.Options) > 0 {
+            compareValues = func(a, b int) int { return cmp.Compare(col.Code(a), col.Code(b)) }
+            break
+        }
+        // Options that are all numbers or range labels, like the years of
+        // experience in the Stack Overflow export, are sorted by number
+        nums := make([]float64, len(entry.UsedOptions))
+        numeric := true
+        for code, opt := range entry.UsedOptions {
+            if nums[code], ok = parseNumericLabel(opt); !ok {
+                numeric = false
+                break
+            }
+        }
+        if numeric {
+            compareValues = func(a, b int) int { return cmp.Compare(nums[col.Code(a)], nums[col.Code(b)]) }
+            break
+        }
+        lower := make([]string, len(entry.UsedOptions))
+        for code, opt := range entry.UsedOptions {
+            lower[code] = strings.ToLower(opt)
+        }
+        compareValues = func(a, b int) int { return strings.Compare(lower[col.Code(a)], lower[col.Code(b)]) }
+    case TE:
+        compareValues = func(a, b int) int {
+            x, _ := col.Value(a).AsString()
+            y, _ := col.Value(b).AsString()
+            return strings.Compare(strings.ToLower(x), strings.ToLower(y))
+        }
+    default:
+        return nil, fmt.Errorf("can't sort by %s question %q", entry.QType, key.Key)
+    }
+
+    return func(a, b int) int {
+        pa, pb := col.Present(a), col.Present(b)
+        switch {
+        case !pa && !pb:
+            return 0
+        case !pa || !pb:
+            // Missing answers go to their place regardless of direction
+            if pa == key.NAFirst {
+                return 1
+            }
+            return -1
+        }
+        if key.Desc {
+            return compareValues(b, a)
+        }
+        return compareValues(a, b)
+    }, nil
+}
@@ -0,0 +1,53 @@
+package survey
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestResponseQuery_Sort(t *testing.T) {
+    // Rows: 0 Germany/Young/3, 1 France/Old/25, 2 Germany/Middle/n/a, 3 n/a/n/a/10
+    sd := whereTestData()
+    tests := []struct {
+        query string
+        want  []int
+    }{
+        {"sort: Years", []int{0, 3, 1, 2}},
+        {"sort: Years desc", []int{1, 3, 0, 2}},
+        {"sort: Years desc na first", []int{2, 1, 3, 0}},
+        {"sort: Years NA first", []int{2, 0, 3, 1}},
+        {"sort: Age desc", []int{1, 2, 0, 3}},            // declared order, not alphabetical
+        {"sort: Country, Years desc", []int{1, 0, 2, 3}}, // ties broken by the next key
+        {"sort: Country desc, Age", []int{0, 2, 1, 3}},
+        {"sort: 'Work mode' desc", []int{0, 3, 2, 1}}, // stable for equal answers
+        {"sort: Years desc; range: [first..first+1]", []int{1, 3}},
+        {"where: Country = Germany; sort: Age desc", []int{2, 0}},
+        {"sort: Exp desc", []int{2, 3, 1, 0}}, // range labels by number
+    }
+    for _, tt := range tests {
+        q, err := ParseResponseQuery(tt.query)
+        if err != nil {
+            t.Errorf("ParseResponseQuery(%q) failed: %v", tt.query, err)
+            continue
+        }
+        got, err := q.Select(sd, sd.Rows())
+        if err != nil || !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%q selects %v, %v; want %v", tt.query, got, err, tt.want)
+        }
+    }
+
+    for _, bad := range []string{"sort:", "sort: Years up", "sort: Years,", "sort: 'Years"} {
+        if _, err := ParseResponseQuery(bad); err == nil {
+            t.Errorf("ParseResponseQuery(%q) should fail", bad)
+        }
+    }
+    for _, bad := range []string{"sort: Lang", "sort: Missing"} {
+        q, err := ParseResponseQuery(bad)
+        if err != nil {
+            t.Fatalf("ParseResponseQuery(%q) failed: %v", bad, err)
+        }
+        if _, err := q.Select(sd, sd.Rows()); err == nil {
+            t.Errorf("%q should fail on the test data", bad)
+        }
+    }
+}
@@ -0,0 +1,215 @@
+package survey
+
+import (
+    "fmt"
+    "path/filepath"
+    "slices"
+    "sort"
+    "strings"
+)
+
+// Source is a survey dataset in some file format. It provides the question
+// schema and the raw response rows; parsing the cells into typed values is
+// left to ReadSurveyData so that all formats behave the same.
+type Source interface {
+    // Files returns the files the dataset consists of, main file first.
+    Files() []string
+    Schema() (Schema, error)
+    // Rows returns an iterator over the raw data, header row first.
+    Rows() (RowReader, error)
+    Close() error
+}
+
+// RowReader iterates over raw data rows.
+type RowReader interface {
+    Next() bool
+    Columns() ([]string, error)
+    Error() error
+    // Estimate returns the estimated number of data rows below the header,
+    // or 0 if it isn't known.
+    Estimate() int
+    Close() error
+}
+
+type sourceFormat struct {
+    exts []string
+    open func(filename string) (Source, error)
+    // files returns the files a source consists of without opening it
+    files func(filename string) []string
+}
+
+var sourceFormats = map[string]sourceFormat{
+    "xlsx": {exts: []string{".xlsx"}, open: openXLSXSource, files: xlsxFiles},
+    "csv":  {exts: []string{".csv"}, open: openCSVSource, files: csvFiles},
+}
+
+// SourceFormats returns the names of all supported input formats.
+func SourceFormats() []string {
+    names := make([]string, 0, len(sourceFormats))
+    for name := range sourceFormats {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// OpenSource opens a dataset in the named format. An empty format picks the
+// format by the file extension.
+func OpenSource(filename, format string) (Source, error) {
+    sf, err := lookupSourceFormat(filename, format)
+    if err != nil {
+        return nil, err
+    }
+    return sf.open(filename)
+}
+
+// SourceFiles returns the files a dataset in the named format consists of,
+// like Source.Files, but without opening it.
+func SourceFiles(filename, format string) ([]string, error) {
+    sf, err := lookupSourceFormat(filename, format)
+    if err != nil {
+        return nil, err
+    }
+    return sf.files(filename), nil
+}
+
+func lookupSourceFormat(filename, format string) (sourceFormat, error) {
+    if format == "" {
+        ext := strings.ToLower(filepath.Ext(filename))
+        for name, sf := range sourceFormats {
+            if slices.Contains(sf.exts, ext) {
+                format = name
+                break
+            }
+        }
+        if format == "" {
+            return sourceFormat{}, fmt.Errorf("no input format for %q, use one of %s", filename, strings.Join(SourceFormats(), ", "))
+        }
+    }
+    sf, ok := sourceFormats[format]
+    if !ok {
+        return sourceFormat{}, fmt.Errorf("unknown input format %q, use one of %s", format, strings.Join(SourceFormats(), ", "))
+    }
+    return sf, nil
+}
+
+// Names of the schema columns, in order of preference. The first names
+// are the ones used by the xlsx export, the others those of the schema
+// file Stack Overflow publishes next to the CSV results.
+var (
+    schemaKeyColumns      = []string{"column", "qname", "key"}
+    schemaTextColumns     = []string{"question_text", "question", "text"}
+    schemaTypeColumns     = []string{"type"}
+    schemaSelectorColumns = []string{"selector"}
+    schemaBlockColumns    = []string{"block", "section"}
+    schemaMatrixColumns   = []string{"matrix", "parent"}
+    schemaScaleColumns    = []string{"scale"}
+    schemaOptionsColumns  = []string{"options"}
+    schemaOrdinalColumns  = []string{"ordinal"}
+)
+
+// selectorTypes maps the answer selectors of the published schema file to
+// question types.
+var selectorTypes = map[string]QuestionType{
+    "SAVR": SC,
+    "SAHR": SC,
+    "DL":   SC,
+    "MAVR": MC,
+    "MAHR": MC,
+    "SL":   TE,
+    "ML":   TE,
+    "ESTB": TE,
+}
+
+func findColumn(header []string, names []string) int {
+    for _, name := range names {
+        for i, h := range header {
+            if strings.EqualFold(strings.TrimSpace(h), name) {
+                return i
+            }
+        }
+    }
+    return -1
+}
+
+// splitList splits a ";" separated list of a schema cell, dropping empty
+// elements.
+func splitList(s string) []string {
+    var out []string
+    for _, v := range strings.Split(s, ";") {
+        if v = strings.TrimSpace(v); v != "" {
+            out = append(out, v)
+        }
+    }
+    return out
+}
+
+// parseFlag reports whether a schema cell marks a flag as set.
+func parseFlag(s string) bool {
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "yes", "y", "true", "1", "x":
+        return true
+    }
+    return false
+}
+
+// parseSchemaRows builds a Schema from the rows of a schema sheet or file.
+// Columns are located by their header names; if these aren't recognized,
+// the first three columns are taken as key, text and type. An empty cell in
+// the optional block column continues the block of the row above. The
+// optional matrix and scale columns declare matrix questions, see
+// linkMatrices; the options and ordinal columns declare the option list of
+// SC and MC questions. Duplicate keys are kept for ReadSurveyData to
+// report.
+func parseSchemaRows(rows [][]string) Schema {
+    schema := make(Schema, 0)
+    if len(rows) == 0 {
+        return schema
+    }
+    header := rows[0]
+    keyCol := findColumn(header, schemaKeyColumns)
+    textCol := findColumn(header, schemaTextColumns)
+    typeCol := findColumn(header, schemaTypeColumns)
+    selectorCol := findColumn(header, schemaSelectorColumns)
+    blockCol := findColumn(header, schemaBlockColumns)
+    matrixCol := findColumn(header, schemaMatrixColumns)
+    scaleCol := findColumn(header, schemaScaleColumns)
+    optionsCol := findColumn(header, schemaOptionsColumns)
+    ordinalCol := findColumn(header, schemaOrdinalColumns)
+    if keyCol < 0 || textCol < 0 || typeCol < 0 {
+        keyCol, textCol, typeCol, selectorCol = 0, 1, 2, -1
+        blockCol, matrixCol, scaleCol, optionsCol, ordinalCol = -1, -1, -1, -1, -1
+    }
+    cell := func(row []string, i int) string {
+        if i < 0 || i >= len(row) {
+            return ""
+        }
+        return row[i]
+    }
+    block := ""
+    for _, row := range rows[1:] {
+        if len(row) <= max(keyCol, textCol, typeCol) {
+            continue
+        }
+        qtype := QuestionType(row[typeCol])
+        if t, ok := selectorTypes[cell(row, selectorCol)]; ok {
+            qtype = t
+        }
+        if b := strings.TrimSpace(cell(row, blockCol)); b != "" {
+            block = b
+        }
+        entry := &SchemaEntry{Key: row[keyCol], Text: row[textCol], QType: qtype, Block: block, UsedOptions: make([]string, 0)}
+        entry.Matrix = strings.TrimSpace(cell(row, matrixCol))
+        switch qtype {
+        case SC, MC:
+            entry.Options = splitList(cell(row, optionsCol))
+            entry.Ordinal = parseFlag(cell(row, ordinalCol))
+        case MATRIX:
+            if entry.Scale = splitList(cell(row, scaleCol)); entry.Scale == nil {
+                entry.Scale = splitList(cell(row, optionsCol))
+            }
+        }
+        schema = append(schema, entry)
+    }
+    return schema
+}
@@ -0,0 +1,100 @@
+package survey
+
+import (
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+func TestOpenSource_Format(t *testing.T) {
+    xlsxFile := filepath.Join(".", "so_test.xlsx")
+
+    src, err := OpenSource(xlsxFile, "")
+    if err != nil {
+        t.Fatalf("OpenSource by extension failed: %v", err)
+    }
+    if _, ok := src.(*xlsxSource); !ok {
+        t.Errorf("OpenSource(%q) = %T, want *xlsxSource", xlsxFile, src)
+    }
+    src.Close()
+
+    if _, err := OpenSource(xlsxFile, "csv"); err == nil {
+        t.Error("expected error opening xlsx file as csv without schema file")
+    }
+    if _, err := OpenSource(xlsxFile, "parquet"); err == nil {
+        t.Error("expected error for unknown format")
+    }
+    if _, err := OpenSource("data.txt", ""); err == nil {
+        t.Error("expected error for unknown extension")
+    }
+}
+
+func TestSourceFiles(t *testing.T) {
+    xlsxFile := filepath.Join(".", "so_test.xlsx")
+    src, err := OpenSource(xlsxFile, "")
+    if err != nil {
+        t.Fatalf("OpenSource failed: %v", err)
+    }
+    defer src.Close()
+    if files, err := SourceFiles(xlsxFile, ""); err != nil || !slices.Equal(files, src.Files()) {
+        t.Errorf("SourceFiles(%q) = %q, %v; want %q", xlsxFile, files, err, src.Files())
+    }
+
+    // The files are named without opening or even finding them
+    files, err := SourceFiles("missing/survey_results_public.csv", "")
+    if want := []string{"missing/survey_results_public.csv", "missing/survey_results_schema.csv"}; err != nil || !slices.Equal(files, want) {
+        t.Errorf("SourceFiles of a missing CSV = %q, %v; want %q", files, err, want)
+    }
+    if _, err := SourceFiles("data.txt", ""); err == nil {
+        t.Error("expected error for unknown extension")
+    }
+}
+
+func TestParseSchemaRows(t *testing.T) {
+    // Unknown header names fall back to key, text, type positions
+    schema := parseSchemaRows([][]string{
+        {"a", "b", "c"},
+        {"Q1", "Question 1", "SC"},
+        {"Q2", "Question 2"},
+        {"Q1", "Duplicate", "MC"},
+    })
+    if len(schema) != 2 || schema[0].Key != "Q1" || schema[0].QType != SC {
+        t.Errorf("positional schema = %+v", schema)
+    }
+    // Duplicates are dropped and reported when the schema is validated
+    ds := &diagnostics{}
+    schema = validSchema(schema, ds)
+    if len(schema) != 1 || len(ds.list) != 1 || ds.list[0].Kind != DiagDuplicateKey {
+        t.Errorf("validated schema = %+v, diagnostics = %+v", schema, ds.list)
+    }
+
+    // Named columns may come in any order
+    schema = parseSchemaRows([][]string{
+        {"type", "question_text", "column"},
+        {"MC", "Question 1", "Q1"},
+    })
+    if len(schema) != 1 || schema[0].Key != "Q1" || schema[0].Text != "Question 1" || schema[0].QType != MC {
+        t.Errorf("named schema = %+v", schema[0])
+    }
+
+    // An empty block cell continues the block above
+    schema = parseSchemaRows([][]string{
+        {"column", "question_text", "type", "block"},
+        {"Q1", "Question 1", "SC", "Basic information"},
+        {"Q2", "Question 2", "NUM", ""},
+        {"Q3", "Question 3", "MC", "AI"},
+    })
+    var blocks []string
+    for _, entry := range schema {
+        blocks = append(blocks, entry.Block)
+    }
+    if !slices.Equal(blocks, []string{"Basic information", "Basic information", "AI"}) {
+        t.Errorf("blocks = %q", blocks)
+    }
+    if got := schema.Blocks(); !slices.Equal(got, []string{"Basic information", "AI"}) {
+        t.Errorf("Blocks() = %q", got)
+    }
+    if entries, ok := schema.Block("basic INFORMATION"); !ok || len(entries) != 2 {
+        t.Errorf("Block(basic INFORMATION) = %v, %v", entries, ok)
+    }
+}
@@ -0,0 +1,209 @@
+package survey
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "unicode"
+
+    "golang.org/x/text/runes"
+    "golang.org/x/text/transform"
+    "golang.org/x/text/unicode/norm"
+)
+
+// DefaultStopwords are common English words left out of term and n-gram
+// counts.
+var DefaultStopwords = NewStopwords(strings.Fields(`
+    a about after all also am an and any are as at be because been but by
+    can could did do does doing don for from get got had has have having he
+    her here him his how i if in into is it its just like me more most my
+    no not now of on one only or other our out over same she so some such
+    than that the their them then there these they this those through to
+    too up us very was we were what when where which while who why will
+    with would you your`))
+
+// Stopwords is a set of words to ignore when counting terms.
+type Stopwords map[string]bool
+
+// NewStopwords builds a stopword set. Words are stored case and accent
+// folded so that they match tokens regardless of the folding options.
+func NewStopwords(words []string) Stopwords {
+    sw := make(Stopwords, len(words))
+    for _, w := range words {
+        if w = strings.TrimSpace(w); w != "" {
+            sw[foldText(w, true, true)] = true
+        }
+    }
+    return sw
+}
+
+// LoadStopwords reads a stopword file with one word per line. Empty lines
+// and lines starting with "#" are skipped.
+func LoadStopwords(filename string) (Stopwords, error) {
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    var words []string
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        words = append(words, line)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("failed to read stopwords %s: %w", filename, err)
+    }
+    return NewStopwords(words), nil
+}
+
+func (sw Stopwords) contains(token string) bool {
+    return sw[foldText(token, true, true)]
+}
+
+// foldText lowercases text and/or strips diacritics ("Café" -> "cafe").
+func foldText(s string, foldCase, foldAccents bool) string {
+    if foldAccents {
+        t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+        if folded, _, err := transform.String(t, s); err == nil {
+            s = folded
+        }
+    }
+    if foldCase {
+        s = strings.ToLower(s)
+    }
+    return s
+}
+
+// tokenize splits text into words. Letters, digits and the characters
+// '+' and '#' (as in "C++" and "C#") make up words; everything else
+// separates them.
+func tokenize(s string) []string {
+    return strings.FieldsFunc(s, func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#'
+    })
+}
+
+// TextOptions controls AnalyzeText.
+type TextOptions struct {
+    Stopwords   Stopwords // words to ignore, nil for none
+    FoldCase    bool
+    FoldAccents bool
+    Top         int // number of entries per list, 0 for all
+}
+
+// TermCount is the number of occurrences of a term, n-gram or answer.
+type TermCount struct {
+    Term     string
+    Count    int
+    Weighted float64
+}
+
+// TextAnalysis holds the most frequent terms, bigrams, trigrams and exact
+// answers of a TE question.
+type TextAnalysis struct {
+    Entry    *SchemaEntry
+    Answered int // responses with a non-empty answer
+    NA       int
+    Terms    []TermCount
+    Bigrams  []TermCount
+    Trigrams []TermCount
+    Answers  []TermCount
+}
+
+type termCounter map[string]*TermCount
+
+func (tc termCounter) add(term string, w float64) {
+    c, ok := tc[term]
+    if !ok {
+        c = &TermCount{Term: term}
+        tc[term] = c
+    }
+    c.Count++
+    c.Weighted += w
+}
+
+// top returns the n most frequent entries, ordered by weighted count, then
+// count, then term.
+func (tc termCounter) top(n int) []TermCount {
+    out := make([]TermCount, 0, len(tc))
+    for _, c := range tc {
+        out = append(out, *c)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Weighted != out[j].Weighted {
+            return out[i].Weighted > out[j].Weighted
+        }
+        if out[i].Count != out[j].Count {
+            return out[i].Count > out[j].Count
+        }
+        return out[i].Term < out[j].Term
+    })
+    if n > 0 && len(out) > n {
+        out = out[:n]
+    }
+    return out
+}
+
+// AnalyzeText counts terms, n-grams and exact answers of a TE question
+// over the given rows, or over all responses if rows is nil. Stopwords are
+// left out of the term counts; n-grams may contain them but neither start
+// nor end with one.
+func (sd *SurveyData) AnalyzeText(key string, rows []int, opts TextOptions) (*TextAnalysis, error) {
+    entry, ok := sd.Schema.Get(key)
+    if !ok {
+        return nil, fmt.Errorf("question %q not found", key)
+    }
+    if entry.QType != TE {
+        return nil, fmt.Errorf("question %q is not a text entry question", key)
+    }
+    col, ok := sd.Column(key)
+    if !ok {
+        return nil, fmt.Errorf("question %q has no data", key)
+    }
+    if rows == nil {
+        rows = sd.Rows()
+    }
+
+    ta := &TextAnalysis{Entry: entry}
+    terms, bigrams, trigrams, answers := termCounter{}, termCounter{}, termCounter{}, termCounter{}
+    for _, row := range rows {
+        text, ok := col.Value(row).AsString()
+        text = strings.TrimSpace(text)
+        if !ok || text == "" {
+            ta.NA++
+            continue
+        }
+        ta.Answered++
+        w := sd.Weight(row)
+        text = foldText(text, opts.FoldCase, opts.FoldAccents)
+        answers.add(strings.Join(strings.Fields(text), " "), w)
+
+        tokens := tokenize(text)
+        stop := make([]bool, len(tokens))
+        for i, tok := range tokens {
+            stop[i] = opts.Stopwords.contains(tok)
+            if !stop[i] {
+                terms.add(tok, w)
+            }
+        }
+        for n, counter := range map[int]termCounter{2: bigrams, 3: trigrams} {
+            for i := 0; i+n <= len(tokens); i++ {
+                if stop[i] || stop[i+n-1] {
+                    continue
+                }
+                counter.add(strings.Join(tokens[i:i+n], " "), w)
+            }
+        }
+    }
+    ta.Terms = terms.top(opts.Top)
+    ta.Bigrams = bigrams.top(opts.Top)
+    ta.Trigrams = trigrams.top(opts.Top)
+    ta.Answers = answers.top(opts.Top)
+    return ta, nil
+}
@@ -0,0 +1,68 @@
+package survey
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestTokenize(t *testing.T) {
+    got := tokenize("I like C++, C# and Go-lang (mostly)!")
+    want := []string{"I", "like", "C++", "C#", "and", "Go", "lang", "mostly"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("tokenize = %v, want %v", got, want)
+    }
+    if got := foldText("Café Über", true, true); got != "cafe uber" {
+        t.Errorf("foldText = %q, want %q", got, "cafe uber")
+    }
+    if got := foldText("Café", false, true); got != "Cafe" {
+        t.Errorf("foldText accents only = %q, want %q", got, "Cafe")
+    }
+}
+
+func TestSurveyData_AnalyzeText(t *testing.T) {
+    sd := NewSurveyData(
+        Schema{{Key: "Q", Text: "Comment", QType: TE}},
+        []Response{
+            {"Q": {Val: "The docs are great"}},
+            {"Q": {Val: "the DOCS are great"}},
+            {"Q": {Val: "Great café, great docs"}},
+            {"Q": {Val: "  "}},
+            {"Q": {Val: nil}},
+        },
+    )
+    ta, err := sd.AnalyzeText("Q", nil, TextOptions{Stopwords: DefaultStopwords, FoldCase: true, FoldAccents: true})
+    if err != nil {
+        t.Fatalf("AnalyzeText failed: %v", err)
+    }
+    if ta.Answered != 3 || ta.NA != 2 {
+        t.Errorf("Answered = %d, NA = %d; want 3, 2", ta.Answered, ta.NA)
+    }
+    wantTerms := []TermCount{{"great", 4, 4}, {"docs", 3, 3}, {"cafe", 1, 1}}
+    if !reflect.DeepEqual(ta.Terms, wantTerms) {
+        t.Errorf("Terms = %v, want %v", ta.Terms, wantTerms)
+    }
+    // "the docs" starts and "docs are" ends with a stopword
+    wantBigrams := []TermCount{{"cafe great", 1, 1}, {"great cafe", 1, 1}, {"great docs", 1, 1}}
+    if !reflect.DeepEqual(ta.Bigrams, wantBigrams) {
+        t.Errorf("Bigrams = %v, want %v", ta.Bigrams, wantBigrams)
+    }
+    if len(ta.Trigrams) == 0 || ta.Trigrams[0] != (TermCount{"docs are great", 2, 2}) {
+        t.Errorf("Trigrams = %v", ta.Trigrams)
+    }
+    if ta.Answers[0] != (TermCount{"the docs are great", 2, 2}) {
+        t.Errorf("Answers[0] = %v", ta.Answers[0])
+    }
+
+    // Without folding, the answers differ
+    ta, err = sd.AnalyzeText("Q", []int{0, 1}, TextOptions{Top: 1})
+    if err != nil {
+        t.Fatalf("AnalyzeText failed: %v", err)
+    }
+    if len(ta.Answers) != 1 || ta.Answers[0].Count != 1 {
+        t.Errorf("Answers without folding = %v", ta.Answers)
+    }
+
+    if _, err := NewSurveyData(Schema{{Key: "S", QType: SC}}, nil).AnalyzeText("S", nil, TextOptions{}); err == nil {
+        t.Error("expected error for a non-text question")
+    }
+}
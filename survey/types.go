@@ -1,12 +1,15 @@
 package survey
 
 import (
+    "bufio"
+    "cmp"
     "compress/gzip"
     "encoding/json"
+    "fmt"
     "io"
     "os"
+    "regexp"
     "slices"
-    "sort"
     "strconv"
     "strings"
 )
@@ -14,9 +17,13 @@ import (
 type QuestionType string
 
 const (
-    SC QuestionType = "SC" // Single Choice
-    MC QuestionType = "MC" // Multiple Choice
-    TE QuestionType = "TE" // Text Entry
+    SC  QuestionType = "SC"  // Single Choice
+    MC  QuestionType = "MC"  // Multiple Choice
+    TE  QuestionType = "TE"  // Text Entry
+    NUM QuestionType = "NUM" // Numeric
+    // Matrix (grid) question: a group of SC row questions rated on the same
+    // scale. The matrix itself has no answers.
+    MATRIX QuestionType = "MATRIX"
 )
 
 type SchemaEntry struct {
@@ -24,39 +31,169 @@ type SchemaEntry struct {
     Text        string
     QType       QuestionType
     UsedOptions []string // Tracks used options for SC and MC questions
+    // Options is the option list of an SC or MC question declared in the
+    // schema, in order. Declared options are part of UsedOptions even if
+    // nobody chose them. Ordinal marks the options as an ordered scale.
+    Options []string `json:",omitempty"`
+    Ordinal bool     `json:",omitempty"`
+    // Block names the section of the questionnaire the question belongs
+    // to, e.g. "Basic information". Empty if the schema has no blocks.
+    Block string `json:",omitempty"`
+    // Derived holds the expression of a derived question, see Derive
+    Derived string `json:",omitempty"`
+    // Items lists the keys of the row questions of a MATRIX question and
+    // Scale its shared answer scale, lowest point first. Matrix is set on
+    // the row questions to the key of their matrix.
+    Items  []string `json:",omitempty"`
+    Scale  []string `json:",omitempty"`
+    Matrix string   `json:",omitempty"`
 }
 
 func (s *SchemaEntry) addUsedOptions(vals []string) {
+    added := false
     for _, v := range vals {
         if v == "" {
             continue
         }
         if !slices.Contains(s.UsedOptions, v) {
             s.UsedOptions = append(s.UsedOptions, v)
+            added = true
         }
     }
-    sort.Strings(s.UsedOptions)
+    if added {
+        s.sortOptions()
+    }
+}
+
+// resetOptions sets UsedOptions to the declared options.
+func (s *SchemaEntry) resetOptions() {
+    s.UsedOptions = append(make([]string, 0, len(s.Options)), s.Options...)
+}
+
+// declaredIndex returns the position of opt in the declared options,
+// ignoring case, or -1 if it isn't declared.
+func (s *SchemaEntry) declaredIndex(opt string) int {
+    return slices.IndexFunc(s.Options, func(o string) bool { return strings.EqualFold(o, opt) })
+}
+
+// declaredSpelling returns opt as spelled in the declared options if it
+// matches one ignoring case, so that case variants share an option code.
+func (s *SchemaEntry) declaredSpelling(opt string) string {
+    if i := s.declaredIndex(opt); i >= 0 {
+        return s.Options[i]
+    }
+    return opt
+}
+
+// sortOptions orders UsedOptions: the declared options first, in their
+// declared order, then the others alphabetically.
+func (s *SchemaEntry) sortOptions() {
+    rank := func(opt string) int {
+        if i := s.declaredIndex(opt); i >= 0 {
+            return i
+        }
+        return len(s.Options)
+    }
+    slices.SortStableFunc(s.UsedOptions, func(a, b string) int {
+        if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+            return c
+        }
+        return strings.Compare(a, b)
+    })
+}
+
+// Declared reports whether opt is one of the declared options, ignoring
+// case. Without declared options, every option counts as declared.
+func (s *SchemaEntry) Declared(opt string) bool {
+    return len(s.Options) == 0 || s.declaredIndex(opt) >= 0
 }
 
 func (s *SchemaEntry) ParseValue(val string) ResponseValue {
+    return s.parseValue(val, nil)
+}
+
+// parseValue is ParseValue with an optional function normalizing the
+// option values of SC and MC questions. Options normalized to an empty
+// string are dropped. Options matching a declared option ignoring case
+// are stored in its spelling.
+func (s *SchemaEntry) parseValue(val string, normalize func(string) string) ResponseValue {
     if val == "" || val == "NA" {
         return ResponseValue{Val: nil}
     }
     switch s.QType {
     case SC:
+        if normalize != nil {
+            if val = normalize(val); val == "" {
+                return ResponseValue{Val: nil}
+            }
+        }
+        val = s.declaredSpelling(val)
         s.addUsedOptions([]string{val})
         return ResponseValue{Val: val}
     case MC:
         vals := strings.Split(val, ";")
+        if normalize != nil || len(s.Options) > 0 {
+            normalized := make([]string, 0, len(vals))
+            for _, v := range vals {
+                if normalize != nil {
+                    v = normalize(v)
+                }
+                if v = s.declaredSpelling(v); v != "" && !slices.Contains(normalized, v) {
+                    normalized = append(normalized, v)
+                }
+            }
+            vals = normalized
+        }
         s.addUsedOptions(vals)
         return ResponseValue{Val: vals}
     case TE:
         return ResponseValue{Val: val}
+    case NUM:
+        f, ok := parseNumericLabel(val)
+        if !ok {
+            return ResponseValue{Val: nil}
+        }
+        return ResponseValue{Val: f}
     default:
         return ResponseValue{Val: nil}
     }
 }
 
+var numericLabelRe = regexp.MustCompile(`^(less than|under|more than|over)?\s*(-?\d+(?:\.\d+)?)(?:\s*(?:-|to)\s*(-?\d+(?:\.\d+)?))?(?:\s+[a-z ]*)?$`)
+
+// parseNumericLabel turns a plain number or a range label as used in the
+// survey export into a number. Open-ended labels are mapped to the first
+// value outside the named bound ("Less than 1 year" -> 0, "More than 50
+// years" -> 51), closed ranges ("18-24 years old") to their midpoint.
+func parseNumericLabel(val string) (float64, bool) {
+    val = strings.TrimSpace(val)
+    if f, err := strconv.ParseFloat(val, 64); err == nil {
+        return f, true
+    }
+    m := numericLabelRe.FindStringSubmatch(strings.ToLower(val))
+    if m == nil {
+        return 0, false
+    }
+    bound, err := strconv.ParseFloat(m[2], 64)
+    if err != nil {
+        return 0, false
+    }
+    switch m[1] {
+    case "less than", "under":
+        return max(bound-1, 0), true
+    case "more than", "over":
+        return bound + 1, true
+    }
+    if m[3] != "" {
+        upper, err := strconv.ParseFloat(m[3], 64)
+        if err != nil {
+            return 0, false
+        }
+        return (bound + upper) / 2, true
+    }
+    return bound, true
+}
+
 func (s *SchemaEntry) matches(str string) bool {
     if strings.Contains(strings.ToLower(s.Key), str) ||
         strings.Contains(strings.ToLower(s.Text), str) {
@@ -92,6 +229,30 @@ func (s Schema) SearchForString(str string) []*SchemaEntry {
     return out
 }
 
+// Blocks returns the names of the question blocks in the order of their
+// first question. Questions without a block are not counted.
+func (s Schema) Blocks() []string {
+    var out []string
+    for _, entry := range s {
+        if entry.Block != "" && !slices.Contains(out, entry.Block) {
+            out = append(out, entry.Block)
+        }
+    }
+    return out
+}
+
+// Block returns the questions of a block, in schema order. The name is
+// compared ignoring case.
+func (s Schema) Block(name string) ([]*SchemaEntry, bool) {
+    var out []*SchemaEntry
+    for _, entry := range s {
+        if entry.Block != "" && strings.EqualFold(entry.Block, name) {
+            out = append(out, entry)
+        }
+    }
+    return out, len(out) > 0
+}
+
 func (s *Schema) add(key, text string, qtype QuestionType) {
     _, exists := s.Get(key)
     if exists {
@@ -113,6 +274,8 @@ func (rv ResponseValue) AsString() (string, bool) {
         return v, true
     case int:
         return strconv.Itoa(v), true
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64), true
     case []string:
         return strings.Join(v, ";"), true
     default:
@@ -120,6 +283,22 @@ func (rv ResponseValue) AsString() (string, bool) {
     }
 }
 
+func (rv ResponseValue) AsFloat() (float64, bool) {
+    if rv.Val == nil {
+        return 0, false
+    }
+    switch v := rv.Val.(type) {
+    case float64:
+        return v, true
+    case int:
+        return float64(v), true
+    case string:
+        return parseNumericLabel(v)
+    default:
+        return 0, false
+    }
+}
+
 func (rv ResponseValue) AsStringSlice() ([]string, bool) {
     if rv.Val == nil {
         return nil, false
@@ -148,17 +327,215 @@ func (rv ResponseValue) Present() bool {
     return rv.Val != nil
 }
 
+// Type tags used by the JSON encoding of ResponseValue.
+const (
+    jsonTypeString  = "s"
+    jsonTypeStrings = "ss"
+    jsonTypeInt     = "i"
+    jsonTypeFloat   = "f"
+)
+
+type typedJSONValue struct {
+    Type  string          `json:"t"`
+    Value json.RawMessage `json:"v"`
+}
+
+// MarshalJSON records the Go type of Val next to the value so that a
+// round-trip through the cache yields exactly the value read from the source.
+func (rv ResponseValue) MarshalJSON() ([]byte, error) {
+    var typ string
+    switch rv.Val.(type) {
+    case nil:
+        return []byte("null"), nil
+    case string:
+        typ = jsonTypeString
+    case []string:
+        typ = jsonTypeStrings
+    case int:
+        typ = jsonTypeInt
+    case float64:
+        typ = jsonTypeFloat
+    default:
+        return nil, fmt.Errorf("unsupported response value type %T", rv.Val)
+    }
+    raw, err := json.Marshal(rv.Val)
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(typedJSONValue{Type: typ, Value: raw})
+}
+
+func (rv *ResponseValue) UnmarshalJSON(data []byte) error {
+    if string(data) == "null" {
+        rv.Val = nil
+        return nil
+    }
+    var tv typedJSONValue
+    if err := json.Unmarshal(data, &tv); err != nil {
+        return err
+    }
+    switch tv.Type {
+    case jsonTypeString:
+        var v string
+        if err := json.Unmarshal(tv.Value, &v); err != nil {
+            return err
+        }
+        rv.Val = v
+    case jsonTypeStrings:
+        v := []string{}
+        if err := json.Unmarshal(tv.Value, &v); err != nil {
+            return err
+        }
+        rv.Val = v
+    case jsonTypeInt:
+        var v int
+        if err := json.Unmarshal(tv.Value, &v); err != nil {
+            return err
+        }
+        rv.Val = v
+    case jsonTypeFloat:
+        var v float64
+        if err := json.Unmarshal(tv.Value, &v); err != nil {
+            return err
+        }
+        rv.Val = v
+    case "":
+        return fmt.Errorf("response value %s has no type", data)
+    default:
+        return fmt.Errorf("unknown response value type %q", tv.Type)
+    }
+    return nil
+}
+
 type Response map[string]ResponseValue
 
+// SurveyData holds the schema and all responses of a survey. Responses are
+// stored column by column; use Len, Value and Response to access them.
 type SurveyData struct {
-    Schema    Schema
-    Responses []Response
+    Schema     Schema
+    Sources    []SourceInfo // the original source files, if known
+    LoadedFrom string       // the file the data was actually read from
+    // WeightSource describes where the respondent weights came from, or is
+    // empty if the data is unweighted
+    WeightSource string
+    // RulesFile is the normalization rules file the data was read with, or
+    // the one merges should be recorded in
+    RulesFile string
+    // Project holds the analysis definitions applied to the data
+    Project *Project
+    // Diagnostics lists the problems found while reading the source files
+    Diagnostics []Diagnostic
+    // IDKey is the question holding the respondent IDs, see ID. It is
+    // detected from the schema when the data is loaded.
+    IDKey   string
+    idIndex map[string]int
+    columns map[string]*Column
+    n       int
+    weights []float64
 }
 
+// NewSurveyData builds SurveyData from responses given as maps.
+func NewSurveyData(schema Schema, responses []Response) *SurveyData {
+    b := newDataBuilder(schema)
+    for _, resp := range responses {
+        b.add(resp)
+    }
+    return b.build()
+}
+
+// Len returns the number of responses.
+func (sd *SurveyData) Len() int {
+    return sd.n
+}
+
+// Rows returns the indices of all responses.
+func (sd *SurveyData) Rows() []int {
+    rows := make([]int, sd.n)
+    for i := range rows {
+        rows[i] = i
+    }
+    return rows
+}
+
+func (sd *SurveyData) Column(key string) (*Column, bool) {
+    col, ok := sd.columns[key]
+    return col, ok
+}
+
+func (sd *SurveyData) Value(row int, key string) ResponseValue {
+    col, ok := sd.columns[key]
+    if !ok {
+        return ResponseValue{Val: nil}
+    }
+    return col.Value(row)
+}
+
+// Response returns all values of one response.
+func (sd *SurveyData) Response(row int) Response {
+    resp := make(Response, len(sd.Schema))
+    for _, entry := range sd.Schema {
+        resp[entry.Key] = sd.Value(row, entry.Key)
+    }
+    return resp
+}
+
+// Responses returns the responses for the given row indices.
+func (sd *SurveyData) Responses(rows []int) []Response {
+    out := make([]Response, len(rows))
+    for i, row := range rows {
+        out[i] = sd.Response(row)
+    }
+    return out
+}
+
+// WriteJSON writes the schema and responses as JSON for LoadSurveyData.
+// Responses are marshaled and written one at a time, so the document is
+// never held in memory as a whole.
 func (sd *SurveyData) WriteJSON(w io.Writer) error {
-    enc := json.NewEncoder(w)
-    enc.SetIndent("", "  ")
-    return enc.Encode(sd)
+    bw := bufio.NewWriter(w)
+    bw.WriteByte('{')
+    if header := sd.cacheHeader(); header != nil {
+        bw.WriteString(`"Header":`)
+        if err := writeJSONValue(bw, header); err != nil {
+            return err
+        }
+        bw.WriteByte(',')
+    }
+    bw.WriteString(`"Schema":`)
+    if err := writeJSONValue(bw, sd.Schema); err != nil {
+        return err
+    }
+    bw.WriteString(`,"Responses":[`)
+    for row := 0; row < sd.n; row++ {
+        if row > 0 {
+            bw.WriteString(",\n")
+        } else {
+            bw.WriteByte('\n')
+        }
+        if err := writeJSONValue(bw, sd.Response(row)); err != nil {
+            return err
+        }
+    }
+    bw.WriteString("\n]}\n")
+    return bw.Flush()
+}
+
+func writeJSONValue(w io.Writer, v any) error {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(b)
+    return err
+}
+
+// WriteToFile writes a cache file in the format LoadSurveyDataFromFile
+// picks for the file name.
+func (sd *SurveyData) WriteToFile(filename string) error {
+    if isBinaryFile(filename) {
+        return sd.WriteBinaryToFile(filename)
+    }
+    return sd.WriteJSONToFile(filename)
 }
 
 func (sd *SurveyData) WriteJSONToFile(filename string) error {
@@ -181,26 +558,27 @@ func (sd *SurveyData) CreateSubset(questionKey string, optionSearch string) []Re
     if !found {
         return result
     }
+    col, ok := sd.Column(questionKey)
+    if !ok {
+        return result
+    }
+    // Match against the option dictionary once instead of every value
     optionSearchLower := strings.ToLower(optionSearch)
-    for _, resp := range sd.Responses {
-        val, ok := resp[questionKey]
-        if !ok || !val.Present() {
-            continue
-        }
+    matching := make([]bool, len(entry.UsedOptions))
+    for i, opt := range entry.UsedOptions {
+        matching[i] = strings.Contains(strings.ToLower(opt), optionSearchLower)
+    }
+    for row := 0; row < sd.n; row++ {
         switch entry.QType {
         case SC:
-            s, ok := val.AsString()
-            if ok && strings.Contains(strings.ToLower(s), optionSearchLower) {
-                result = append(result, resp)
+            if code := col.Code(row); code >= 0 && matching[code] {
+                result = append(result, sd.Response(row))
             }
         case MC:
-            ss, ok := val.AsStringSlice()
-            if ok {
-                for _, opt := range ss {
-                    if strings.Contains(strings.ToLower(opt), optionSearchLower) {
-                        result = append(result, resp)
-                        break
-                    }
+            for _, code := range col.Codes(row) {
+                if matching[code] {
+                    result = append(result, sd.Response(row))
+                    break
                 }
             }
         }
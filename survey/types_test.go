@@ -3,6 +3,8 @@ package survey
 import (
     "bytes"
     "encoding/json"
+    "reflect"
+    "slices"
     "testing"
 )
 
@@ -31,13 +33,13 @@ func TestResponseValue_AsMethods(t *testing.T) {
 
 func TestSurveyData_WriteJSON_and_LoadSurveyData(t *testing.T) {
     // Minimal SurveyData for roundtrip
-    sd := &SurveyData{
-        Schema: Schema{
+    sd := NewSurveyData(
+        Schema{
             {Key: "Q1", Text: "Question 1", QType: SC, UsedOptions: []string{"foo"}},
             {Key: "Q2", Text: "Question 2", QType: MC, UsedOptions: []string{"a", "b"}},
             {Key: "Q3", Text: "Question 3", QType: TE, UsedOptions: []string{}},
         },
-        Responses: []Response{
+        []Response{
             {
                 "Q1": {Val: "foo"},
                 "Q2": {Val: []string{"a", "b"}},
@@ -49,7 +51,7 @@ func TestSurveyData_WriteJSON_and_LoadSurveyData(t *testing.T) {
                 "Q3": {Val: nil},
             },
         },
-    }
+    )
 
     var buf bytes.Buffer
     if err := sd.WriteJSON(&buf); err != nil {
@@ -70,8 +72,8 @@ func TestSurveyData_WriteJSON_and_LoadSurveyData(t *testing.T) {
     if len(loaded.Schema) != len(sd.Schema) {
         t.Errorf("Schema length mismatch: got %d, want %d", len(loaded.Schema), len(sd.Schema))
     }
-    if len(loaded.Responses) != len(sd.Responses) {
-        t.Errorf("Responses length mismatch: got %d, want %d", len(loaded.Responses), len(sd.Responses))
+    if loaded.Len() != sd.Len() {
+        t.Errorf("Responses length mismatch: got %d, want %d", loaded.Len(), sd.Len())
     }
 }
 
@@ -126,10 +128,10 @@ func TestSurveyData_CreateSubset(t *testing.T) {
         {"Q1": ResponseValue{Val: "green"}, "Q2": ResponseValue{Val: []string{"Java"}}, "Q3": ResponseValue{Val: "Okay!"}},
         {"Q1": ResponseValue{Val: "red"}, "Q2": ResponseValue{Val: []string{"Python"}}, "Q3": ResponseValue{Val: "Great!"}},
     }
-    sd := &SurveyData{
-        Schema:    schema,
-        Responses: responses,
-    }
+    sd := NewSurveyData(
+        schema,
+        responses,
+    )
 
     // SC: search for "red" in Q1
     subset := sd.CreateSubset("Q1", "red")
@@ -167,3 +169,159 @@ func TestSurveyData_CreateSubset(t *testing.T) {
         t.Errorf("CreateSubset TE: got %d, want 0", len(subset))
     }
 }
+
+func TestSchemaEntry_ParseValue_Numeric(t *testing.T) {
+    entry := &SchemaEntry{Key: "YearsCode", Text: "Years coding", QType: NUM}
+
+    tests := []struct {
+        input   string
+        want    float64
+        present bool
+    }{
+        {"20", 20, true},
+        {"3.5", 3.5, true},
+        {"Less than 1 year", 0, true},
+        {"More than 50 years", 51, true},
+        {"Under 18 years old", 17, true},
+        {"18-24 years old", 21, true},
+        {"NA", 0, false},
+        {"", 0, false},
+        {"lots", 0, false},
+    }
+
+    for _, tt := range tests {
+        val := entry.ParseValue(tt.input)
+        if val.Present() != tt.present {
+            t.Errorf("ParseValue(%q).Present() = %v, want %v", tt.input, val.Present(), tt.present)
+            continue
+        }
+        if !tt.present {
+            continue
+        }
+        got, ok := val.AsFloat()
+        if !ok || got != tt.want {
+            t.Errorf("ParseValue(%q).AsFloat() = %v, %v; want %v", tt.input, got, ok, tt.want)
+        }
+    }
+    if len(entry.UsedOptions) != 0 {
+        t.Errorf("numeric entry should not track used options, got %v", entry.UsedOptions)
+    }
+}
+
+func TestSchemaEntry_DeclaredIgnoresCase(t *testing.T) {
+    entry := &SchemaEntry{Key: "Q", Text: "Agree?", QType: SC, Options: []string{"Disagree", "Neutral", "Agree"}, Ordinal: true}
+    entry.resetOptions()
+    for _, v := range []string{"agree", "other", "disagree"} {
+        entry.ParseValue(v)
+    }
+    if !entry.Declared("disagree") || entry.Declared("other") {
+        t.Errorf("Declared(disagree) = %v, Declared(other) = %v; want true, false", entry.Declared("disagree"), entry.Declared("other"))
+    }
+    // Case variants are stored as the declared option, so they share its code
+    if got := entry.ParseValue("AGREE"); got.Val != "Agree" {
+        t.Errorf("ParseValue(AGREE) = %v, want Agree", got.Val)
+    }
+    want := []string{"Disagree", "Neutral", "Agree", "other"}
+    if !slices.Equal(entry.UsedOptions, want) {
+        t.Errorf("UsedOptions = %q, want %q", entry.UsedOptions, want)
+    }
+
+    mc := &SchemaEntry{Key: "Lang", Text: "Languages", QType: MC, Options: []string{"Go", "Rust"}}
+    if got := mc.ParseValue("go;Go;rust;Zig"); !reflect.DeepEqual(got.Val, []string{"Go", "Rust", "Zig"}) {
+        t.Errorf("ParseValue(go;Go;rust;Zig) = %q, want [Go Rust Zig]", got.Val)
+    }
+}
+
+func TestSurveyData_WriteJSON_Numeric(t *testing.T) {
+    sd := NewSurveyData(
+        Schema{
+            {Key: "YearsCode", Text: "Years coding", QType: NUM},
+        },
+        []Response{
+            {"YearsCode": {Val: 51.0}},
+            {"YearsCode": {Val: nil}},
+        },
+    )
+
+    var buf bytes.Buffer
+    if err := sd.WriteJSON(&buf); err != nil {
+        t.Fatalf("WriteJSON failed: %v", err)
+    }
+    loaded, err := LoadSurveyData(&buf)
+    if err != nil {
+        t.Fatalf("LoadSurveyData failed: %v", err)
+    }
+
+    if loaded.Schema[0].QType != NUM {
+        t.Errorf("QType = %q, want %q", loaded.Schema[0].QType, NUM)
+    }
+    if v, ok := loaded.Response(0)["YearsCode"].AsFloat(); !ok || v != 51 {
+        t.Errorf("AsFloat() = %v, %v; want 51, true", v, ok)
+    }
+    if loaded.Response(1)["YearsCode"].Present() {
+        t.Error("missing numeric value should not be present after round-trip")
+    }
+}
+
+func TestResponseValue_JSONRoundTrip(t *testing.T) {
+    tests := []struct {
+        name string
+        val  ResponseValue
+    }{
+        {"nil", ResponseValue{Val: nil}},
+        {"string", ResponseValue{Val: "foo"}},
+        {"numeric string", ResponseValue{Val: "42"}},
+        {"string slice", ResponseValue{Val: []string{"a", "b"}}},
+        {"empty string slice", ResponseValue{Val: []string{}}},
+        {"int", ResponseValue{Val: 42}},
+        {"float", ResponseValue{Val: 2.5}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, err := json.Marshal(tt.val)
+            if err != nil {
+                t.Fatalf("Marshal failed: %v", err)
+            }
+            var got ResponseValue
+            if err := json.Unmarshal(data, &got); err != nil {
+                t.Fatalf("Unmarshal(%s) failed: %v", data, err)
+            }
+            if !reflect.DeepEqual(got, tt.val) {
+                t.Errorf("round-trip of %s = %#v, want %#v", data, got, tt.val)
+            }
+        })
+    }
+}
+
+func TestSurveyData_WriteJSON_PreservesValueTypes(t *testing.T) {
+    sd := NewSurveyData(
+        Schema{
+            {Key: "Q1", Text: "Question 1", QType: SC},
+            {Key: "Q2", Text: "Question 2", QType: MC},
+            {Key: "Q3", Text: "Question 3", QType: TE},
+            {Key: "Q4", Text: "Question 4", QType: NUM},
+        },
+        []Response{
+            {"Q1": {Val: "foo"}, "Q2": {Val: []string{"a", "b"}}, "Q3": {Val: "42"}, "Q4": {Val: 2.5}},
+            {"Q1": {Val: nil}, "Q2": {Val: nil}, "Q3": {Val: nil}, "Q4": {Val: nil}},
+        },
+    )
+
+    var buf bytes.Buffer
+    if err := sd.WriteJSON(&buf); err != nil {
+        t.Fatalf("WriteJSON failed: %v", err)
+    }
+    loaded, err := LoadSurveyData(&buf)
+    if err != nil {
+        t.Fatalf("LoadSurveyData failed: %v", err)
+    }
+    for row := 0; row < sd.Len(); row++ {
+        if !reflect.DeepEqual(loaded.Response(row), sd.Response(row)) {
+            t.Errorf("loaded response %d = %#v, want %#v", row, loaded.Response(row), sd.Response(row))
+        }
+    }
+    if s, ok := loaded.Response(0)["Q2"].AsString(); !ok || s != "a;b" {
+        t.Errorf("AsString() on loaded MC value = %q, %v; want \"a;b\", true", s, ok)
+    }
+}
@@ -0,0 +1,134 @@
+package survey
+
+import (
+    "encoding/csv"
+    "fmt"
+    "math"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// Weighted reports whether respondent weights are set.
+func (sd *SurveyData) Weighted() bool {
+    return sd.weights != nil
+}
+
+// Weight returns the weight of a response, which is 1 for unweighted data.
+func (sd *SurveyData) Weight(row int) float64 {
+    if sd.weights == nil {
+        return 1
+    }
+    return sd.weights[row]
+}
+
+// Weights returns the respondent weights, or nil for unweighted data.
+func (sd *SurveyData) Weights() []float64 {
+    return sd.weights
+}
+
+// WeightedCount returns the sum of the weights of the given rows.
+func (sd *SurveyData) WeightedCount(rows []int) float64 {
+    sum := 0.0
+    for _, row := range rows {
+        sum += sd.Weight(row)
+    }
+    return sum
+}
+
+// SetWeights sets one weight per response. Weights must be finite and not
+// negative. Passing nil removes the weights.
+func (sd *SurveyData) SetWeights(weights []float64, source string) error {
+    if weights == nil {
+        sd.weights = nil
+        sd.WeightSource = ""
+        return nil
+    }
+    if len(weights) != sd.n {
+        return fmt.Errorf("got %d weights for %d responses", len(weights), sd.n)
+    }
+    for row, w := range weights {
+        if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+            return fmt.Errorf("invalid weight %v for response %d", w, row+1)
+        }
+    }
+    sd.weights = weights
+    sd.WeightSource = source
+    return nil
+}
+
+// UseWeightColumn takes the respondent weights from a numeric question.
+func (sd *SurveyData) UseWeightColumn(key string) error {
+    entry, ok := sd.Schema.Get(key)
+    if !ok {
+        return fmt.Errorf("question %q not found", key)
+    }
+    if entry.QType != NUM {
+        return fmt.Errorf("question %q is not numeric", key)
+    }
+    col, ok := sd.Column(key)
+    if !ok {
+        return fmt.Errorf("question %q has no data", key)
+    }
+    weights := make([]float64, sd.n)
+    missing := 0
+    for row := range weights {
+        w, ok := col.Float(row)
+        if !ok {
+            missing++
+            continue
+        }
+        weights[row] = w
+    }
+    if missing > 0 {
+        return fmt.Errorf("question %q has no weight for %d responses", key, missing)
+    }
+    return sd.SetWeights(weights, "column "+key)
+}
+
+// LoadWeights reads respondent weights from a CSV file with the columns
+// "id,weight", keyed by the respondent ID, see ID. A header row is skipped
+// if its weight isn't a number. Every response needs a weight.
+func (sd *SurveyData) LoadWeights(filename string) error {
+    f, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    cr := csv.NewReader(f)
+    cr.FieldsPerRecord = -1
+    records, err := cr.ReadAll()
+    if err != nil {
+        return fmt.Errorf("failed to read weights %s: %w", filename, err)
+    }
+
+    byID := make(map[string]float64, len(records))
+    for i, rec := range records {
+        if len(rec) < 2 {
+            return fmt.Errorf("weights %s line %d: expected id and weight", filename, i+1)
+        }
+        w, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+        if err != nil {
+            if i == 0 {
+                continue
+            }
+            return fmt.Errorf("weights %s line %d: invalid weight %q", filename, i+1, rec[1])
+        }
+        byID[strings.TrimSpace(rec[0])] = w
+    }
+
+    weights := make([]float64, sd.n)
+    missing := 0
+    for row := range weights {
+        w, ok := byID[sd.ID(row)]
+        if !ok {
+            missing++
+            continue
+        }
+        weights[row] = w
+    }
+    if missing > 0 {
+        return fmt.Errorf("weights %s has no weight for %d responses", filename, missing)
+    }
+    return sd.SetWeights(weights, filename)
+}
@@ -0,0 +1,104 @@
+package survey
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func weightTestData() *SurveyData {
+    return NewSurveyData(
+        Schema{
+            {Key: "ResponseId", Text: "ID", QType: TE},
+            {Key: "Q1", Text: "Color", QType: SC},
+            {Key: "W", Text: "Weight", QType: NUM},
+        },
+        []Response{
+            {"ResponseId": {Val: "a"}, "Q1": {Val: "red"}, "W": {Val: 2.0}},
+            {"ResponseId": {Val: "b"}, "Q1": {Val: "blue"}, "W": {Val: 0.5}},
+            {"ResponseId": {Val: "c"}, "Q1": {Val: "red"}, "W": {Val: 1.0}},
+            {"ResponseId": {Val: "d"}, "Q1": {Val: nil}, "W": {Val: 0.5}},
+        },
+    )
+}
+
+func TestSurveyData_UseWeightColumn(t *testing.T) {
+    sd := weightTestData()
+    if sd.Weighted() || sd.Weight(0) != 1 {
+        t.Fatal("new data should be unweighted")
+    }
+    if err := sd.UseWeightColumn("W"); err != nil {
+        t.Fatalf("UseWeightColumn failed: %v", err)
+    }
+    if !reflect.DeepEqual(sd.Weights(), []float64{2, 0.5, 1, 0.5}) {
+        t.Errorf("Weights() = %v", sd.Weights())
+    }
+    if got := sd.WeightedCount([]int{0, 1}); got != 2.5 {
+        t.Errorf("WeightedCount = %v, want 2.5", got)
+    }
+
+    dist, err := sd.Distribution("Q1", nil)
+    if err != nil {
+        t.Fatalf("Distribution failed: %v", err)
+    }
+    // UsedOptions are [blue red]
+    if !reflect.DeepEqual(dist.Counts, []int{1, 2}) || !reflect.DeepEqual(dist.Weighted, []float64{0.5, 3}) {
+        t.Errorf("Counts = %v, Weighted = %v", dist.Counts, dist.Weighted)
+    }
+    if dist.WeightedNA != 0.5 || dist.WeightedRespondents != 3.5 || dist.WeightedTotal() != 4 {
+        t.Errorf("WeightedNA = %v, WeightedRespondents = %v, WeightedTotal = %v",
+            dist.WeightedNA, dist.WeightedRespondents, dist.WeightedTotal())
+    }
+
+    if err := sd.UseWeightColumn("Q1"); err == nil {
+        t.Error("expected error for a non-numeric weight question")
+    }
+    if err := sd.SetWeights([]float64{1, -1, 1, 1}, "test"); err == nil {
+        t.Error("expected error for a negative weight")
+    }
+    if err := sd.SetWeights(nil, ""); err != nil || sd.Weighted() {
+        t.Errorf("SetWeights(nil) = %v, Weighted() = %v", err, sd.Weighted())
+    }
+}
+
+func TestSurveyData_LoadWeights(t *testing.T) {
+    dir := t.TempDir()
+    filename := filepath.Join(dir, "weights.csv")
+    if err := os.WriteFile(filename, []byte("id,weight\nb,3\na,1.5\nc,1\nd,0\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    sd := weightTestData()
+    if err := sd.LoadWeights(filename); err != nil {
+        t.Fatalf("LoadWeights failed: %v", err)
+    }
+    if !reflect.DeepEqual(sd.Weights(), []float64{1.5, 3, 1, 0}) {
+        t.Errorf("Weights() = %v", sd.Weights())
+    }
+    if sd.WeightSource != filename {
+        t.Errorf("WeightSource = %q, want %q", sd.WeightSource, filename)
+    }
+
+    incomplete := filepath.Join(dir, "incomplete.csv")
+    if err := os.WriteFile(incomplete, []byte("a,1\nb,1\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := sd.LoadWeights(incomplete); err == nil {
+        t.Error("expected error for responses without a weight")
+    }
+
+    // Without an ID question, rows are identified by their number
+    byRow := filepath.Join(dir, "rows.csv")
+    if err := os.WriteFile(byRow, []byte("1,1\n2,2\n3,3\n4,4\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := sd.SetIDKey(""); err != nil {
+        t.Fatal(err)
+    }
+    if err := sd.LoadWeights(byRow); err != nil {
+        t.Fatalf("LoadWeights by row failed: %v", err)
+    }
+    if !reflect.DeepEqual(sd.Weights(), []float64{1, 2, 3, 4}) {
+        t.Errorf("Weights() by row = %v", sd.Weights())
+    }
+}
@@ -0,0 +1,524 @@
+package survey
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "strings"
+    "unicode"
+)
+
+// Filters select responses by their answers. They are the where section of
+// a ResponseQuery:
+//
+//    Country = Germany                      SC, TE equality (also !=)
+//    Country in ("Germany", "France")       SC, TE membership (also not in)
+//    Lang has Go                            MC contains (same as has any)
+//    Lang has any (Go, Rust)                MC contains any of the options
+//    Lang has all (Go, Rust)                MC contains all of the options
+//    Age is na, Age is not na               missing or present answer
+//    YearsCode >= 10                        NUM comparison (= != < <= > >=)
+//    Age < "35-44 years old"                ordinal SC comparison
+//    not (A or B) and C                     logic with parentheses
+//
+// Keys containing spaces or other characters are written in brackets:
+// [OpSysProfessional use] has Linux, with a ] in the key doubled. Values
+// may be quoted or bare words. Options are compared ignoring case. A
+// missing answer never matches a comparison; use "is na" to select it.
+
+// Filter is a parsed where section. Its question keys are resolved when
+// it is applied to survey data.
+type Filter struct {
+    root filterNode
+}
+
+type filterNode interface{}
+
+type filterLogic struct {
+    op   string // "and", "or"
+    x, y filterNode
+}
+
+type filterNot struct{ x filterNode }
+
+type filterCond struct {
+    key    string
+    op     string // "is na", "=", "!=", "<", "<=", ">", ">=", "in", "has any", "has all"
+    values []string
+}
+
+// parseFilter parses a filter starting at the rune offset start of the
+// query input, so that syntax errors report positions in the input.
+func parseFilter(input string, start int) (*Filter, error) {
+    rs := []rune(input)
+    src := string(rs[start:])
+    tokens, err := lexExpr(src)
+    if err != nil {
+        if se, ok := err.(*SyntaxError); ok && se.Line == 1 {
+            return nil, syntaxError(input, start+se.Column-1, "%s", se.Msg)
+        }
+        return nil, err
+    }
+    for i := range tokens {
+        tokens[i].pos += start
+    }
+    p := &filterParser{input: input, tokens: tokens, end: len(rs)}
+    if len(tokens) == 0 {
+        return nil, p.errorf("empty filter")
+    }
+    root, err := p.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    if p.pos < len(p.tokens) {
+        return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+    }
+    return &Filter{root: root}, nil
+}
+
+type filterParser struct {
+    input  string
+    tokens []exprToken
+    pos    int
+    end    int // rune offset of the end of the input, for errors at the end
+}
+
+// errorf returns a SyntaxError at the current token.
+func (p *filterParser) errorf(format string, args ...any) error {
+    pos := p.end
+    if p.pos < len(p.tokens) {
+        pos = p.tokens[p.pos].pos
+    }
+    return syntaxError(p.input, pos, format, args...)
+}
+
+// keyword consumes the next token if it is one of the given keywords or
+// operators, compared ignoring case.
+func (p *filterParser) keyword(words ...string) (string, bool) {
+    if p.pos >= len(p.tokens) {
+        return "", false
+    }
+    tok := p.tokens[p.pos]
+    if tok.kind != tokIdent && tok.kind != tokOp {
+        return "", false
+    }
+    for _, w := range words {
+        if strings.EqualFold(tok.text, w) {
+            p.pos++
+            return w, true
+        }
+    }
+    return "", false
+}
+
+func (p *filterParser) expect(word string) error {
+    if _, ok := p.keyword(word); !ok {
+        return p.errorf("expected %q", word)
+    }
+    return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+    x, err := p.parseAnd()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        if _, ok := p.keyword("or", "||"); !ok {
+            return x, nil
+        }
+        y, err := p.parseAnd()
+        if err != nil {
+            return nil, err
+        }
+        x = &filterLogic{"or", x, y}
+    }
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+    x, err := p.parseNot()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        if _, ok := p.keyword("and", "&&"); !ok {
+            return x, nil
+        }
+        y, err := p.parseNot()
+        if err != nil {
+            return nil, err
+        }
+        x = &filterLogic{"and", x, y}
+    }
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+    if _, ok := p.keyword("not", "!"); ok {
+        x, err := p.parseNot()
+        if err != nil {
+            return nil, err
+        }
+        return &filterNot{x}, nil
+    }
+    if _, ok := p.keyword("("); ok {
+        x, err := p.parseOr()
+        if err != nil {
+            return nil, err
+        }
+        return x, p.expect(")")
+    }
+    return p.parseCond()
+}
+
+func (p *filterParser) parseCond() (filterNode, error) {
+    if p.pos >= len(p.tokens) {
+        return nil, p.errorf("expected a question key")
+    }
+    tok := p.tokens[p.pos]
+    if tok.kind != tokIdent && tok.kind != tokKey {
+        return nil, p.errorf("expected a question key, got %q", tok.text)
+    }
+    p.pos++
+    cond := &filterCond{key: tok.text}
+
+    if _, ok := p.keyword("is"); ok {
+        _, negate := p.keyword("not")
+        if err := p.expect("na"); err != nil {
+            return nil, err
+        }
+        cond.op = "is na"
+        if negate {
+            return &filterNot{cond}, nil
+        }
+        return cond, nil
+    }
+    if _, ok := p.keyword("not"); ok {
+        if err := p.expect("in"); err != nil {
+            return nil, err
+        }
+        cond.op = "in"
+        values, err := p.parseValueList()
+        if err != nil {
+            return nil, err
+        }
+        cond.values = values
+        return &filterNot{cond}, nil
+    }
+    if _, ok := p.keyword("in"); ok {
+        cond.op = "in"
+        values, err := p.parseValueList()
+        cond.values = values
+        return cond, err
+    }
+    if _, ok := p.keyword("has"); ok {
+        cond.op = "has any"
+        if w, ok := p.keyword("any", "all"); ok {
+            cond.op = "has " + w
+        }
+        if p.pos < len(p.tokens) && p.tokens[p.pos].text == "(" && p.tokens[p.pos].kind == tokOp {
+            values, err := p.parseValueList()
+            cond.values = values
+            return cond, err
+        }
+        value, err := p.parseValue()
+        cond.values = []string{value}
+        return cond, err
+    }
+    op, ok := p.keyword("==", "=", "!=", "<=", ">=", "<", ">")
+    if !ok {
+        return nil, p.errorf("expected an operator after %q", cond.key)
+    }
+    if op == "==" {
+        op = "="
+    }
+    cond.op = op
+    value, err := p.parseValue()
+    cond.values = []string{value}
+    return cond, err
+}
+
+// parseValue parses a string, a number (optionally negative) or a bare word.
+func (p *filterParser) parseValue() (string, error) {
+    if p.pos >= len(p.tokens) {
+        return "", p.errorf("expected a value")
+    }
+    tok := p.tokens[p.pos]
+    if tok.kind == tokOp && tok.text == "-" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokNumber {
+        p.pos += 2
+        return "-" + p.tokens[p.pos-1].text, nil
+    }
+    if tok.kind == tokOp || tok.kind == tokKey {
+        return "", p.errorf("expected a value, got %q", tok.text)
+    }
+    p.pos++
+    return tok.text, nil
+}
+
+func (p *filterParser) parseValueList() ([]string, error) {
+    if err := p.expect("("); err != nil {
+        return nil, err
+    }
+    var values []string
+    for {
+        v, err := p.parseValue()
+        if err != nil {
+            return nil, err
+        }
+        values = append(values, v)
+        if _, ok := p.keyword(","); !ok {
+            break
+        }
+    }
+    return values, p.expect(")")
+}
+
+// rowMatcher reports whether a response matches a filter.
+type rowMatcher func(row int) bool
+
+// compile resolves the filter's question keys against the data.
+func (f *Filter) compile(sd *SurveyData) (rowMatcher, error) {
+    return compileFilter(sd, f.root)
+}
+
+func compileFilter(sd *SurveyData, node filterNode) (rowMatcher, error) {
+    switch n := node.(type) {
+    case *filterLogic:
+        x, err := compileFilter(sd, n.x)
+        if err != nil {
+            return nil, err
+        }
+        y, err := compileFilter(sd, n.y)
+        if err != nil {
+            return nil, err
+        }
+        if n.op == "and" {
+            return func(row int) bool { return x(row) && y(row) }, nil
+        }
+        return func(row int) bool { return x(row) || y(row) }, nil
+    case *filterNot:
+        x, err := compileFilter(sd, n.x)
+        if err != nil {
+            return nil, err
+        }
+        return func(row int) bool { return !x(row) }, nil
+    case *filterCond:
+        return compileCond(sd, n)
+    }
+    return nil, fmt.Errorf("invalid filter")
+}
+
+func compileCond(sd *SurveyData, c *filterCond) (rowMatcher, error) {
+    entry, ok := sd.Schema.Get(c.key)
+    if !ok {
+        return nil, fmt.Errorf("unknown question %q", c.key)
+    }
+    col, ok := sd.Column(c.key)
+    if !ok {
+        return func(int) bool { return c.op == "is na" }, nil
+    }
+    if c.op == "is na" {
+        if entry.QType == MC {
+            return func(row int) bool { return len(col.Codes(row)) == 0 }, nil
+        }
+        return func(row int) bool { return !col.Present(row) }, nil
+    }
+    typeError := func() error {
+        return fmt.Errorf("%q can't be used with %s question %q", c.op, entry.QType, c.key)
+    }
+
+    switch entry.QType {
+    case SC:
+        if c.op == "=" || c.op == "!=" || c.op == "in" {
+            want := make([]bool, len(entry.UsedOptions))
+            for code, opt := range entry.UsedOptions {
+                want[code] = containsFold(c.values, opt)
+            }
+            neq := c.op == "!="
+            return func(row int) bool {
+                code := col.Code(row)
+                return code >= 0 && want[code] != neq
+            }, nil
+        }
+        if strings.HasPrefix(c.op, "has") {
+            return nil, typeError()
+        }
+        // Order comparisons follow the declared options of ordinal questions
+        if !entry.Ordinal || len(entry.Options) == 0 {
+            return nil, fmt.Errorf("%q needs a numeric or ordinal question, %q is neither", c.op, c.key)
+        }
+        bound := entry.declaredIndex(c.values[0])
+        if bound < 0 {
+            return nil, fmt.Errorf("%q is not an option of %q", c.values[0], c.key)
+        }
+        rank := make([]int, len(entry.UsedOptions))
+        for code, opt := range entry.UsedOptions {
+            rank[code] = entry.declaredIndex(opt)
+        }
+        return func(row int) bool {
+            code := col.Code(row)
+            return code >= 0 && rank[code] >= 0 && compareOrdered(rank[code], bound, c.op)
+        }, nil
+    case MC:
+        if !strings.HasPrefix(c.op, "has") {
+            return nil, fmt.Errorf("use has, has any or has all with multiple-choice question %q", c.key)
+        }
+        // Map each value to the option code it matches, -1 if none
+        codes := make([]int32, len(c.values))
+        for i, v := range c.values {
+            codes[i] = int32(slices.IndexFunc(entry.UsedOptions, func(opt string) bool { return strings.EqualFold(opt, v) }))
+        }
+        all := c.op == "has all"
+        return func(row int) bool {
+            chosen := col.Codes(row)
+            if len(chosen) == 0 {
+                return false
+            }
+            for _, code := range codes {
+                has := code >= 0 && slices.Contains(chosen, code)
+                if has != all {
+                    return has
+                }
+            }
+            return all
+        }, nil
+    case NUM:
+        if strings.HasPrefix(c.op, "has") {
+            return nil, typeError()
+        }
+        nums := make([]float64, len(c.values))
+        for i, v := range c.values {
+            f, ok := parseNumericLabel(v)
+            if !ok {
+                return nil, fmt.Errorf("%q is not a number for numeric question %q", v, c.key)
+            }
+            nums[i] = f
+        }
+        if c.op == "in" {
+            return func(row int) bool {
+                f, ok := col.Float(row)
+                return ok && slices.Contains(nums, f)
+            }, nil
+        }
+        return func(row int) bool {
+            f, ok := col.Float(row)
+            return ok && compareOrdered(f, nums[0], c.op)
+        }, nil
+    case TE:
+        if c.op != "=" && c.op != "!=" && c.op != "in" {
+            return nil, typeError()
+        }
+        neq := c.op == "!="
+        return func(row int) bool {
+            if !col.Present(row) {
+                return false
+            }
+            s, _ := col.Value(row).AsString()
+            return containsFold(c.values, s) != neq
+        }, nil
+    }
+    return nil, typeError()
+}
+
+func compareOrdered[T int | float64](a, b T, op string) bool {
+    switch op {
+    case "=":
+        return a == b
+    case "!=":
+        return a != b
+    case "<":
+        return a < b
+    case "<=":
+        return a <= b
+    case ">":
+        return a > b
+    case ">=":
+        return a >= b
+    }
+    return false
+}
+
+// filterKeywords can't be written as bare keys or values.
+var filterKeywords = []string{"and", "or", "not", "is", "na", "in", "has", "any", "all"}
+
+// String returns the filter in canonical form: lowercase keywords, keys in
+// brackets and values in quotes where needed, and parentheses only where
+// the precedence requires them.
+func (f *Filter) String() string {
+    return formatFilter(f.root, 1)
+}
+
+// formatFilter formats a node inside an operator of the given precedence:
+// 1 for or, 2 for and, 3 for not.
+func formatFilter(node filterNode, prec int) string {
+    switch n := node.(type) {
+    case *filterLogic:
+        // Both operators are left associative
+        p := 1
+        if n.op == "and" {
+            p = 2
+        }
+        s := formatFilter(n.x, p) + " " + n.op + " " + formatFilter(n.y, p+1)
+        if prec > p {
+            return "(" + s + ")"
+        }
+        return s
+    case *filterNot:
+        if c, ok := n.x.(*filterCond); ok && c.op == "is na" {
+            return formatFilterKey(c.key) + " is not na"
+        }
+        if c, ok := n.x.(*filterCond); ok && c.op == "in" {
+            return formatFilterKey(c.key) + " not in " + formatFilterValues(c.values)
+        }
+        return "not " + formatFilter(n.x, 3)
+    case *filterCond:
+        key := formatFilterKey(n.key)
+        switch {
+        case n.op == "is na":
+            return key + " is na"
+        case n.op == "in":
+            return key + " in " + formatFilterValues(n.values)
+        case n.op == "has any" && len(n.values) == 1:
+            return key + " has " + formatFilterValue(n.values[0])
+        case strings.HasPrefix(n.op, "has"):
+            return key + " " + n.op + " " + formatFilterValues(n.values)
+        }
+        return key + " " + n.op + " " + formatFilterValue(n.values[0])
+    }
+    return ""
+}
+
+// isFilterWord reports whether s lexes as a single identifier that isn't
+// a keyword.
+func isFilterWord(s string) bool {
+    for i, r := range s {
+        if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+            return false
+        }
+    }
+    return s != "" && !containsFold(filterKeywords, s)
+}
+
+func formatFilterKey(key string) string {
+    if isFilterWord(key) {
+        return key
+    }
+    return "[" + strings.ReplaceAll(key, "]", "]]") + "]"
+}
+
+var filterNumberRe = regexp.MustCompile(`^-?(\d[\d.]*|\.\d[\d.]*)$`)
+
+func formatFilterValue(v string) string {
+    if isFilterWord(v) || filterNumberRe.MatchString(v) {
+        return v
+    }
+    r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+    return `"` + r.Replace(v) + `"`
+}
+
+func formatFilterValues(values []string) string {
+    out := make([]string, len(values))
+    for i, v := range values {
+        out[i] = formatFilterValue(v)
+    }
+    return "(" + strings.Join(out, ", ") + ")"
+}
@@ -0,0 +1,125 @@
+package survey
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+)
+
+func whereTestData() *SurveyData {
+    schema := Schema{
+        {Key: "Country", Text: "Country", QType: SC},
+        {Key: "Age", Text: "Age", QType: SC, Options: []string{"Young", "Middle", "Old"}, Ordinal: true},
+        {Key: "Lang", Text: "Languages", QType: MC},
+        {Key: "Years", Text: "Years of coding", QType: NUM},
+        {Key: "Work mode", Text: "Work mode", QType: TE},
+        {Key: "Exp", Text: "Years of experience", QType: SC},
+    }
+    return NewSurveyData(schema, []Response{
+        {"Country": {Val: "Germany"}, "Age": {Val: "Young"}, "Lang": {Val: []string{"Go", "Rust"}}, "Years": {Val: 3.0}, "Work mode": {Val: "remote"}, "Exp": {Val: "Less than 1 year"}},
+        {"Country": {Val: "France"}, "Age": {Val: "Old"}, "Lang": {Val: []string{"Go"}}, "Years": {Val: 25.0}, "Work mode": {Val: nil}, "Exp": {Val: "5"}},
+        {"Country": {Val: "Germany"}, "Age": {Val: "Middle"}, "Lang": {Val: nil}, "Years": {Val: nil}, "Work mode": {Val: "office"}, "Exp": {Val: "More than 50 years"}},
+        {"Country": {Val: nil}, "Age": {Val: nil}, "Lang": {Val: []string{"Rust", "Python"}}, "Years": {Val: 10.0}, "Work mode": {Val: "Remote"}, "Exp": {Val: "10"}},
+    })
+}
+
+func TestResponseQuery_Where(t *testing.T) {
+    sd := whereTestData()
+    tests := []struct {
+        where string
+        want  []int
+    }{
+        {`Country = Germany`, []int{0, 2}},
+        {`Country == "germany"`, []int{0, 2}},
+        {`Country != Germany`, []int{1}},
+        {`Country in (France, Spain)`, []int{1}},
+        {`Country not in (France)`, []int{0, 2, 3}},
+        {`Country is na`, []int{3}},
+        {`Lang is not na`, []int{0, 1, 3}},
+        {`Lang has Rust`, []int{0, 3}},
+        {`Lang has any (Python, Go)`, []int{0, 1, 3}},
+        {`Lang has all (Go, Rust)`, []int{0}},
+        {`Lang has all (Go, Java)`, []int{}},
+        {`Years >= 10`, []int{1, 3}},
+        {`Years < 10 or Years is na`, []int{0, 2}},
+        {`Years in (3, 25)`, []int{0, 1}},
+        {`Age > Young`, []int{1, 2}},
+        {`Age <= middle`, []int{0, 2}},
+        {`[Work mode] = remote`, []int{0, 3}},
+        {`not (Country = Germany or Lang has Python) and Years > 0`, []int{1}},
+        {`NOT Country = France AND Lang HAS Go`, []int{0}},
+    }
+    for _, tt := range tests {
+        q, err := ParseResponseQuery("where: " + tt.where)
+        if err != nil {
+            t.Errorf("ParseResponseQuery(%q) failed: %v", tt.where, err)
+            continue
+        }
+        got, err := q.Select(sd, sd.Rows())
+        if err != nil || !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("where %q selects %v, %v; want %v", tt.where, got, err, tt.want)
+        }
+    }
+
+    // The filter is applied before the range
+    q, err := ParseResponseQuery("range: [first..first]; where: Lang has Rust")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, _ := q.Select(sd, sd.Rows()); !reflect.DeepEqual(got, []int{0}) {
+        t.Errorf("filtered range selects %v, want [0]", got)
+    }
+
+    // Separators inside quoted values don't end the section
+    q, err = ParseResponseQuery(`where: Country = "A;B" or Country = Germany; range: [first..first]`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, _ := q.Select(sd, sd.Rows()); !reflect.DeepEqual(got, []int{0}) {
+        t.Errorf("where with a quoted separator selects %v, want [0]", got)
+    }
+
+    // Conditions that don't fit the schema fail when applied
+    for _, where := range []string{
+        `Missing = 1`,
+        `Country > Germany`,
+        `Country has Germany`,
+        `Lang = Go`,
+        `Years = many`,
+        `Age < Ancient`,
+    } {
+        q, err := ParseResponseQuery("where: " + where)
+        if err != nil {
+            t.Errorf("ParseResponseQuery(%q) failed: %v", where, err)
+            continue
+        }
+        if _, err := q.Select(sd, sd.Rows()); err == nil {
+            t.Errorf("where %q should fail on the test data", where)
+        }
+    }
+}
+
+func TestParseResponseQuery_WhereErrors(t *testing.T) {
+    tests := []struct {
+        query  string
+        column int
+    }{
+        {`where: Country =`, 17},
+        {`where: Country Germany`, 16},
+        {`where: (Country = Germany`, 26},
+        {`where: Lang has any (Go,)`, 25},
+        {`keys: Age; where: Age = "Old`, 25},
+        {`where:`, 7},
+    }
+    for _, tt := range tests {
+        _, err := ParseResponseQuery(tt.query)
+        var se *SyntaxError
+        if !errors.As(err, &se) {
+            t.Errorf("ParseResponseQuery(%q) = %v, want a syntax error", tt.query, err)
+            continue
+        }
+        if se.Column != tt.column {
+            t.Errorf("ParseResponseQuery(%q) error %q at column %d, want %d", tt.query, se.Msg, se.Column, tt.column)
+        }
+    }
+}
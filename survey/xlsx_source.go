@@ -0,0 +1,80 @@
+package survey
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/xuri/excelize/v2"
+)
+
+// xlsxSource reads a workbook with a "schema" and a "raw data" sheet.
+type xlsxSource struct {
+    filename string
+    f        *excelize.File
+}
+
+func xlsxFiles(filename string) []string {
+    return []string{filename}
+}
+
+func openXLSXSource(filename string) (Source, error) {
+    f, err := excelize.OpenFile(filename)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open file: %w", err)
+    }
+    return &xlsxSource{filename: filename, f: f}, nil
+}
+
+func (s *xlsxSource) Files() []string {
+    return xlsxFiles(s.filename)
+}
+
+func (s *xlsxSource) Schema() (Schema, error) {
+    rows, err := s.f.GetRows("schema")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read schema sheet: %w", err)
+    }
+    return parseSchemaRows(rows), nil
+}
+
+func (s *xlsxSource) Rows() (RowReader, error) {
+    rows, err := s.f.Rows("raw data")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read \"raw data\" sheet: %w", err)
+    }
+    return &xlsxRows{rows: rows, estimate: estimateDataRows(s.f, "raw data")}, nil
+}
+
+func (s *xlsxSource) Close() error {
+    return s.f.Close()
+}
+
+// xlsxRows streams the rows of a sheet through excelize's row iterator.
+type xlsxRows struct {
+    rows     *excelize.Rows
+    estimate int
+}
+
+func (r *xlsxRows) Next() bool                 { return r.rows.Next() }
+func (r *xlsxRows) Columns() ([]string, error) { return r.rows.Columns() }
+func (r *xlsxRows) Error() error               { return r.rows.Error() }
+func (r *xlsxRows) Estimate() int              { return r.estimate }
+func (r *xlsxRows) Close() error               { return r.rows.Close() }
+
+// estimateDataRows returns the number of rows below the header according
+// to the sheet's dimension record, or 0 if the sheet doesn't declare one.
+func estimateDataRows(f *excelize.File, sheet string) int {
+    dim, err := f.GetSheetDimension(sheet)
+    if err != nil {
+        return 0
+    }
+    _, last, found := strings.Cut(dim, ":")
+    if !found {
+        return 0
+    }
+    _, row, err := excelize.CellNameToCoordinates(last)
+    if err != nil || row < 1 {
+        return 0
+    }
+    return row - 1
+}